@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"math"
+	"math/rand/v2"
 	"net/http"
 	_ "net/http/pprof"
 	"runtime"
@@ -25,11 +27,61 @@ func checkGLError() {
 	}
 }
 
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// openWorldInfo loads the information of the world, or creates it from the command line if the world is new
+func openWorldInfo(storage *level.WorldStorage, requested level.WorldInfo) (level.WorldInfo, error) {
+	info, ok, err := storage.LoadInfo()
+	if err != nil {
+		return info, fmt.Errorf("LoadInfo(): %w", err)
+	}
+
+	if ok {
+		if isFlagSet("seed") && requested.Seed != info.Seed {
+			return info, fmt.Errorf("the world was created with seed %d, not %d", info.Seed, requested.Seed)
+		}
+		if isFlagSet("generator") && requested.Generator != info.Generator {
+			return info, fmt.Errorf("the world was created with generator \"%s\", not \"%s\"", info.Generator, requested.Generator)
+		}
+		if isFlagSet("preset") && requested.Preset != info.Preset {
+			return info, fmt.Errorf("the world was created with preset \"%s\", not \"%s\"", info.Preset, requested.Preset)
+		}
+		return info, nil
+	}
+
+	if !isFlagSet("seed") {
+		requested.Seed = rand.Int64()
+	}
+	if requested.Generator != level.GENERATOR_FLAT {
+		requested.Preset = ""
+	}
+	info = requested
+	err = storage.SaveInfo(info)
+	if err != nil {
+		return info, fmt.Errorf("SaveInfo(): %w", err)
+	}
+	return info, nil
+}
+
 func init() {
 	runtime.LockOSThread()
 }
 
 func main() {
+	worldPath := flag.String("world", "./world", "path to the world directory, created if it doesn't exist")
+	seed := flag.Int64("seed", 0, "seed of the world, random if not set, only used when the world is created")
+	generatorName := flag.String("generator", level.GENERATOR_NOISE, "terrain generator of the world (noise, flat or void), only used when the world is created")
+	preset := flag.String("preset", level.DEFAULT_FLAT_PRESET, "layers of the flat generator from the bottom up, i.e. \"stone,2*dirt,grass\"")
+	flag.Parse()
+
 	go func() {
 		http.ListenAndServe("localhost:6060", nil)
 	}()
@@ -59,8 +111,29 @@ func main() {
 	gl.Enable(gl.BLEND)
 	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
 
-	level.LoadBlocks()
-	game := p_game.NewGame(mgl32.Perspective(math.Pi/4, 16.0/9.0, 0.1, 2048))
+	err = level.LoadBlocks("./data/blocks.json")
+	if err != nil {
+		panic(fmt.Errorf("level.LoadBlocks(): %w", err))
+	}
+
+	storage, err := level.OpenWorldStorage(*worldPath)
+	if err != nil {
+		panic(fmt.Errorf("level.OpenWorldStorage(): %w", err))
+	}
+	defer storage.Close()
+
+	info, err := openWorldInfo(storage, level.WorldInfo{Seed: *seed, Generator: *generatorName, Preset: *preset})
+	if err != nil {
+		panic(fmt.Errorf("openWorldInfo(): %w", err))
+	}
+	fmt.Printf("World seed: %d, generator: %s\n", info.Seed, info.Generator)
+
+	generator, err := level.NewGenerator(info)
+	if err != nil {
+		panic(fmt.Errorf("level.NewGenerator(): %w", err))
+	}
+
+	game := p_game.NewGame(mgl32.Perspective(math.Pi/4, 16.0/9.0, 0.1, 2048), storage, generator)
 
 	chunkRenderer, err := graphics.NewChunkRenderer(game)
 	if err != nil {
@@ -75,6 +148,7 @@ func main() {
 	game.Start()
 
 	lastFrame := glfw.GetTime()
+	lastTitle := lastFrame
 	var deltaTime float64
 	var currentTime float64
 
@@ -98,10 +172,20 @@ func main() {
 		if err != nil {
 			panic(fmt.Errorf("Draw(): %w", err))
 		}
+		if currentTime-lastTitle >= 1 {
+			stats := chunkRenderer.Stats()
+			window.SetTitle(fmt.Sprintf("Testing - chunks drawn: %d, culled: %d, occluded: %d", stats.DrawnChunks, stats.CulledChunks, stats.OccludedChunks))
+			lastTitle = currentTime
+		}
 
 		window.SwapBuffers()
 		glfw.PollEvents()
 		game.FrameTick(float32(deltaTime))
 		checkGLError()
 	}
+
+	err = game.Level.Save()
+	if err != nil {
+		panic(fmt.Errorf("Save(): %w", err))
+	}
 }
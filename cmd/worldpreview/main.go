@@ -0,0 +1,212 @@
+/*
+worldpreview renders top-down images of a world generated from a seed, without any window or GPU.
+It writes in the output directory:
+
+	surface.png   colour of the top block, shaded by the slope, seas tinted by their depth
+	height.png    height of the ground, from black at the bottom of the level to white at its top
+	water.png     depth of the water, black on land
+	biomes.png    biome of every column, when the generator places biomes
+
+The images only depend on the flags, so they can be diffed to review terrain changes.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/vparent05/minecraft_go/internal/level"
+)
+
+var WATER_COLOR = color.NRGBA{40, 80, 200, 255}
+
+var BIOME_COLORS = map[level.BiomeId]color.NRGBA{
+	level.OCEAN:        {30, 60, 170, 255},
+	level.FROZEN_OCEAN: {140, 160, 220, 255},
+	level.BEACH:        {230, 215, 140, 255},
+	level.PLAINS:       {140, 200, 90, 255},
+	level.FOREST:       {40, 120, 40, 255},
+	level.SWAMP:        {70, 100, 70, 255},
+	level.DESERT:       {240, 190, 90, 255},
+	level.TUNDRA:       {235, 240, 250, 255},
+	level.MOUNTAINS:    {130, 130, 130, 255},
+}
+
+// averageColor returns the mean colour of the opaque pixels of the png at path
+func averageColor(path string) (color.NRGBA, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("os.Open(): %w", err)
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("png.Decode(): %w", err)
+	}
+
+	var r, g, b, n uint64
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				continue
+			}
+			r, g, b, n = r+uint64(c.R), g+uint64(c.G), b+uint64(c.B), n+1
+		}
+	}
+	if n == 0 {
+		return color.NRGBA{}, nil
+	}
+	return color.NRGBA{uint8(r / n), uint8(g / n), uint8(b / n), 255}, nil
+}
+
+// blockColors returns the average colour of the top texture of every block
+func blockColors(texturesPath string) (map[level.BlockId]color.NRGBA, error) {
+	colors := make(map[level.BlockId]color.NRGBA)
+	for id := range level.BLOCK_TYPES {
+		c, err := averageColor(filepath.Join(texturesPath, id.TopTexture()))
+		if err != nil {
+			return nil, fmt.Errorf("averageColor(): %w", err)
+		}
+		colors[id] = c
+	}
+	return colors, nil
+}
+
+func isWater(b level.BlockId) bool {
+	return b == level.WATER || b == level.ICE
+}
+
+// ground returns the height and the block of the highest block of the column that isn't air or water
+func ground(area *level.Area, x, z int) (int, level.BlockId) {
+	for y := level.MAX_Y - 1; y >= level.MIN_Y; y-- {
+		if b := area.Block(x, y, z); b != level.AIR && !isWater(b) {
+			return y, b
+		}
+	}
+	return level.MIN_Y - 1, level.AIR
+}
+
+func blend(a, b color.NRGBA, t float64) color.NRGBA {
+	mix := func(x, y uint8) uint8 { return uint8(float64(x)*(1-t) + float64(y)*t) }
+	return color.NRGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+func shade(c color.NRGBA, f float64) color.NRGBA {
+	s := func(x uint8) uint8 { return uint8(math.Min(float64(x)*f, 255)) }
+	return color.NRGBA{s(c.R), s(c.G), s(c.B), 255}
+}
+
+func savePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("os.Create(): %w", err)
+	}
+	defer file.Close()
+
+	err = png.Encode(file, img)
+	if err != nil {
+		return fmt.Errorf("png.Encode(): %w", err)
+	}
+	return nil
+}
+
+func main() {
+	seed := flag.Int64("seed", 0, "seed of the world")
+	generatorName := flag.String("generator", level.GENERATOR_NOISE, "world generator: noise, flat or void")
+	preset := flag.String("preset", level.DEFAULT_FLAT_PRESET, "layers of the flat generator, from the bottom up, as [count*]block separated by commas")
+	centerX := flag.Int("x", 0, "x of the block at the center of the images")
+	centerZ := flag.Int("z", 0, "z of the block at the center of the images")
+	size := flag.Int("size", 512, "width and height of the images in blocks")
+	outPath := flag.String("out", ".", "directory the images are written to")
+	texturesPath := flag.String("textures", "./textures/blocks", "directory of the block textures, used for the surface colours")
+	blocksPath := flag.String("blocks", "./data/blocks.json", "block registry")
+	flag.Parse()
+
+	err := level.LoadBlocks(*blocksPath)
+	if err != nil {
+		panic(err)
+	}
+	colors, err := blockColors(*texturesPath)
+	if err != nil {
+		panic(err)
+	}
+
+	generator, err := level.NewGenerator(level.WorldInfo{Seed: *seed, Generator: *generatorName, Preset: *preset})
+	if err != nil {
+		panic(err)
+	}
+
+	minX, minZ := *centerX-*size/2, *centerZ-*size/2
+	// one more column on each side for the slope shading
+	from := level.LevelToChunkCoords(mgl32.Vec3{float32(minX - 1), 0, float32(minZ - 1)})
+	to := level.LevelToChunkCoords(mgl32.Vec3{float32(minX + *size), 0, float32(minZ + *size)})
+	fmt.Printf("Generating %d chunks\n", (to.X-from.X+1)*(to.Y-from.Y+1))
+	area := level.GenerateArea(generator, from, to)
+
+	rect := image.Rect(0, 0, *size, *size)
+	surface := image.NewNRGBA(rect)
+	height := image.NewGray(rect)
+	water := image.NewNRGBA(rect)
+	for i := range *size {
+		for j := range *size {
+			x, z := minX+i, minZ+j
+
+			y, b := ground(area, x, z)
+			top, topBlock := area.Top(x, z)
+			westY, _ := ground(area, x-1, z)
+			northY, _ := ground(area, x, z-1)
+
+			// lit from the north west
+			c := shade(colors[b.Type()], math.Max(0.6, math.Min(1.4, 1+0.08*float64(2*y-westY-northY))))
+			depth := top - y
+			if isWater(topBlock) {
+				c = blend(c, colors[topBlock.Type()], math.Min(0.4+0.04*float64(depth), 0.9))
+				water.SetNRGBA(i, j, shade(WATER_COLOR, 1.2-math.Min(float64(depth)/40, 1)))
+			} else {
+				water.SetNRGBA(i, j, color.NRGBA{0, 0, 0, 255})
+			}
+			surface.SetNRGBA(i, j, c)
+			height.SetGray(i, j, color.Gray{uint8(max(y-level.MIN_Y, 0) * 255 / (level.CHUNK_HEIGHT - 1))})
+		}
+	}
+
+	images := map[string]image.Image{
+		"surface.png": surface,
+		"height.png":  height,
+		"water.png":   water,
+	}
+
+	if source, ok := generator.(level.BiomeSource); ok {
+		biomes := image.NewNRGBA(rect)
+		for i := range *size {
+			for j := range *size {
+				biomes.SetNRGBA(i, j, BIOME_COLORS[source.Biome(minX+i, minZ+j)])
+			}
+		}
+		images["biomes.png"] = biomes
+	} else {
+		fmt.Printf("The %s generator doesn't place biomes, skipping biomes.png\n", *generatorName)
+	}
+
+	err = os.MkdirAll(*outPath, 0755)
+	if err != nil {
+		panic(err)
+	}
+	for name, img := range images {
+		err = savePNG(filepath.Join(*outPath, name), img)
+		if err != nil {
+			panic(err)
+		}
+	}
+	fmt.Println("Wrote the previews of seed", *seed, "to", *outPath)
+}
@@ -0,0 +1,134 @@
+package arena
+
+import (
+	"maps"
+	"slices"
+)
+
+/*
+Arena sub-allocates ranges of a buffer of a fixed size, in units chosen by the caller.
+The free ranges are kept sorted by offset and merged with the free ranges next to them, an allocation takes the first one large enough.
+Compact moves every allocation to the start of a buffer of a new size, removing the gaps left between them.
+*/
+type Arena struct {
+	size        int
+	free        []span // sorted by offset, two of them are never next to each other
+	allocations map[*Allocation]struct{}
+}
+
+// Allocation is a range of the buffer of an arena, its offset changes when the arena is compacted
+type Allocation struct {
+	Offset int
+	Size   int
+}
+
+// Move is a copy of Size units of the buffer from From to To, in the new buffer
+type Move struct {
+	From int
+	To   int
+	Size int
+}
+
+type span struct {
+	offset int
+	size   int
+}
+
+func (s span) end() int {
+	return s.offset + s.size
+}
+
+func New(size int) *Arena {
+	a := &Arena{size: size, allocations: make(map[*Allocation]struct{})}
+	if size > 0 {
+		a.free = []span{{0, size}}
+	}
+	return a
+}
+
+func (a *Arena) Size() int {
+	return a.size
+}
+
+// Used returns the number of units held by allocations
+func (a *Arena) Used() int {
+	used := a.size
+	for _, s := range a.free {
+		used -= s.size
+	}
+	return used
+}
+
+// Alloc returns an allocation of size units, ok is false if no free range is large enough, size must be positive
+func (a *Arena) Alloc(size int) (allocation *Allocation, ok bool) {
+	for i := range a.free {
+		s := &a.free[i]
+		if s.size < size {
+			continue
+		}
+
+		allocation = &Allocation{s.offset, size}
+		s.offset += size
+		s.size -= size
+		if s.size == 0 {
+			a.free = slices.Delete(a.free, i, i+1)
+		}
+		a.allocations[allocation] = struct{}{}
+		return allocation, true
+	}
+	return nil, false
+}
+
+// Release frees the range of the allocation, it must not be used afterwards
+func (a *Arena) Release(allocation *Allocation) {
+	if _, ok := a.allocations[allocation]; !ok {
+		return
+	}
+	delete(a.allocations, allocation)
+
+	released := span{allocation.Offset, allocation.Size}
+	i, _ := slices.BinarySearchFunc(a.free, released.offset, func(s span, offset int) int {
+		return s.offset - offset
+	})
+	mergePrevious := i > 0 && a.free[i-1].end() == released.offset
+	mergeNext := i < len(a.free) && released.end() == a.free[i].offset
+	switch {
+	case mergePrevious && mergeNext:
+		a.free[i-1].size += released.size + a.free[i].size
+		a.free = slices.Delete(a.free, i, i+1)
+	case mergePrevious:
+		a.free[i-1].size += released.size
+	case mergeNext:
+		a.free[i].offset = released.offset
+		a.free[i].size += released.size
+	default:
+		a.free = slices.Insert(a.free, i, released)
+	}
+}
+
+/*
+Compact packs the allocations, in the order of their offsets, at the start of a buffer of size units and updates their offsets.
+It returns the copies to make from the old buffer to the new one, ok is false and nothing changes if the allocations don't fit.
+*/
+func (a *Arena) Compact(size int) (moves []Move, ok bool) {
+	if a.Used() > size {
+		return nil, false
+	}
+
+	allocations := slices.SortedFunc(maps.Keys(a.allocations), func(x, y *Allocation) int {
+		return x.Offset - y.Offset
+	})
+	offset := 0
+	for _, allocation := range allocations {
+		moves = append(moves, Move{allocation.Offset, offset, allocation.Size})
+		allocation.Offset = offset
+		offset += allocation.Size
+	}
+
+	a.size = size
+	a.free = a.free[:0]
+	if offset < size {
+		a.free = append(a.free, span{offset, size - offset})
+	}
+	return moves, true
+}
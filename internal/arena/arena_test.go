@@ -0,0 +1,74 @@
+package arena
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAllocReusesReleasedRanges(t *testing.T) {
+	a := New(10)
+	first, _ := a.Alloc(4)
+	second, _ := a.Alloc(4)
+	if first.Offset != 0 || second.Offset != 4 {
+		t.Fatalf("allocations are at %d and %d, want 0 and 4", first.Offset, second.Offset)
+	}
+	if _, ok := a.Alloc(3); ok {
+		t.Error("3 units were allocated with only 2 free")
+	}
+
+	a.Release(first)
+	if third, ok := a.Alloc(3); !ok || third.Offset != 0 {
+		t.Errorf("the allocation after a release is at %v, want the released range", third)
+	}
+	if a.Used() != 7 {
+		t.Errorf("%d units are used, want 7", a.Used())
+	}
+}
+
+func TestReleaseMergesFreeRanges(t *testing.T) {
+	a := New(9)
+	var allocations []*Allocation
+	for range 3 {
+		allocation, _ := a.Alloc(3)
+		allocations = append(allocations, allocation)
+	}
+
+	// releasing the middle one last merges it with the ranges on both of its sides
+	a.Release(allocations[0])
+	a.Release(allocations[2])
+	a.Release(allocations[1])
+	if len(a.free) != 1 || a.free[0] != (span{0, 9}) {
+		t.Errorf("the free ranges are %v, want the whole arena", a.free)
+	}
+	if _, ok := a.Alloc(9); !ok {
+		t.Error("the whole arena can't be allocated after releasing everything")
+	}
+}
+
+func TestCompactRemovesGaps(t *testing.T) {
+	a := New(10)
+	first, _ := a.Alloc(2)
+	gap, _ := a.Alloc(3)
+	last, _ := a.Alloc(4)
+	a.Release(gap)
+	if _, ok := a.Alloc(4); ok {
+		t.Fatal("4 units were allocated without a free range large enough")
+	}
+
+	if _, ok := a.Compact(5); ok {
+		t.Error("6 units were compacted into 5")
+	}
+	moves, ok := a.Compact(a.Size())
+	if !ok {
+		t.Fatal("the arena can't be compacted to its own size")
+	}
+	if want := []Move{{0, 0, 2}, {5, 2, 4}}; !slices.Equal(moves, want) {
+		t.Errorf("the moves are %v, want %v", moves, want)
+	}
+	if first.Offset != 0 || last.Offset != 2 {
+		t.Errorf("allocations are at %d and %d after compacting, want 0 and 2", first.Offset, last.Offset)
+	}
+	if allocation, ok := a.Alloc(4); !ok || allocation.Offset != 6 {
+		t.Errorf("the allocation after compacting is at %v, want 6", allocation)
+	}
+}
@@ -0,0 +1,48 @@
+// Package frustum tests boxes against the volume a camera sees, without any GPU
+package frustum
+
+import "github.com/go-gl/mathgl/mgl32"
+
+/*
+Frustum is the volume seen through a projection, as the 6 planes bounding it.
+A plane a, b, c, d holds the points where a*x + b*y + c*z + d = 0, the inside of the frustum is where it is positive.
+*/
+type Frustum struct {
+	planes [6]mgl32.Vec4
+}
+
+/*
+FromMatrix returns the frustum of viewProjection, which is projection * view for a camera.
+A point is visible if its clip coordinates are within -w and w on every axis, each bound gives a plane.
+*/
+func FromMatrix(viewProjection mgl32.Mat4) Frustum {
+	x, y, z, w := viewProjection.Row(0), viewProjection.Row(1), viewProjection.Row(2), viewProjection.Row(3)
+	return Frustum{[6]mgl32.Vec4{
+		w.Add(x), // left
+		w.Sub(x), // right
+		w.Add(y), // bottom
+		w.Sub(y), // top
+		w.Add(z), // near
+		w.Sub(z), // far
+	}}
+}
+
+/*
+IntersectsBox returns false if the axis aligned box from min to max is entirely outside the frustum.
+Boxes near the corners of the frustum can be outside of it and still intersect, they are drawn for nothing.
+*/
+func (f Frustum) IntersectsBox(min, max mgl32.Vec3) bool {
+	for _, plane := range f.planes {
+		// the corner furthest inside the plane
+		corner := min
+		for i := range 3 {
+			if plane[i] > 0 {
+				corner[i] = max[i]
+			}
+		}
+		if plane.Vec3().Dot(corner)+plane.W() < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package frustum
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestIntersectsBox(t *testing.T) {
+	// at the origin looking towards -z, with 90 degrees of field of view
+	projection := mgl32.Perspective(math.Pi/2, 1, 0.1, 100)
+	view := mgl32.LookAtV(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 0, -1}, mgl32.Vec3{0, 1, 0})
+	f := FromMatrix(projection.Mul4(view))
+
+	tests := []struct {
+		name     string
+		min, max mgl32.Vec3
+		visible  bool
+	}{
+		{"in front", mgl32.Vec3{-1, -1, -6}, mgl32.Vec3{1, 1, -4}, true},
+		{"behind", mgl32.Vec3{-1, -1, 4}, mgl32.Vec3{1, 1, 6}, false},
+		{"around the camera", mgl32.Vec3{-1, -1, -1}, mgl32.Vec3{1, 1, 1}, true},
+		{"left of the field of view", mgl32.Vec3{-20, -1, -6}, mgl32.Vec3{-10, 1, -4}, false},
+		{"crossing the left side", mgl32.Vec3{-20, -1, -6}, mgl32.Vec3{-5, 1, -4}, true},
+		{"above the field of view", mgl32.Vec3{-1, 10, -6}, mgl32.Vec3{1, 20, -4}, false},
+		{"past the far plane", mgl32.Vec3{-1, -1, -200}, mgl32.Vec3{1, 1, -150}, false},
+		{"crossing the far plane", mgl32.Vec3{-1, -1, -150}, mgl32.Vec3{1, 1, -50}, true},
+	}
+	for _, test := range tests {
+		if visible := f.IntersectsBox(test.min, test.max); visible != test.visible {
+			t.Errorf("box %s: IntersectsBox() = %v, want %v", test.name, visible, test.visible)
+		}
+	}
+}
+
+func TestFromMatrixFollowsView(t *testing.T) {
+	projection := mgl32.Perspective(math.Pi/4, 16.0/9.0, 0.1, 2048)
+	box := [2]mgl32.Vec3{{50, 60, -5}, {65, 75, 10}}
+
+	towards := mgl32.LookAtV(mgl32.Vec3{0, 64, 0}, mgl32.Vec3{1, 64, 0}, mgl32.Vec3{0, 1, 0})
+	if !FromMatrix(projection.Mul4(towards)).IntersectsBox(box[0], box[1]) {
+		t.Error("a box the camera looks at is culled")
+	}
+	away := mgl32.LookAtV(mgl32.Vec3{0, 64, 0}, mgl32.Vec3{-1, 64, 0}, mgl32.Vec3{0, 1, 0})
+	if FromMatrix(projection.Mul4(away)).IntersectsBox(box[0], box[1]) {
+		t.Error("a box behind the camera isn't culled")
+	}
+}
@@ -12,12 +12,12 @@ type Game struct {
 	View       mgl32.Mat4
 }
 
-func NewGame(projection mgl32.Mat4) *Game {
+func NewGame(projection mgl32.Mat4, storage *level.WorldStorage, generator level.Generator) *Game {
 	g := Game{}
 	g.Projection = projection
 
 	g.Player = NewPlayer(&g)
-	g.Level = level.NewLevel(g.Player.levelObserver)
+	g.Level = level.NewLevel(g.Player.levelObserver, storage, generator)
 
 	return &g
 }
@@ -13,6 +13,9 @@ import (
 	"github.com/vparent05/minecraft_go/internal/utils/debounce"
 )
 
+// PLAYER_HITBOX is the box the player collides with, around its position
+var PLAYER_HITBOX = level.Box{Min: mgl32.Vec3{-0.3, -1.5, -0.3}, Max: mgl32.Vec3{0.3, 0.2, 0.3}}
+
 type player struct {
 	*movement.EntityController
 	game                 *Game
@@ -39,6 +42,10 @@ func NewPlayer(game *Game) *player {
 		blockAction:          debounce.NewDebounce(100 * time.Millisecond),
 	}
 
+	p.SetCollider(func(position, displacement mgl32.Vec3) mgl32.Vec3 {
+		return p.game.Level.Clip(PLAYER_HITBOX.Offset(position), displacement)
+	})
+
 	p.levelObserver.Store(p.asLevelObserver())
 	return p
 }
@@ -97,9 +104,12 @@ func (p *player) FrameTick(deltaTime float32) {
 	}
 
 	if glfw.GetCurrentContext().GetMouseButton(glfw.MouseButton2) == glfw.Press {
-		_, front := p.game.Level.CastRay(p.CameraPosition(), p.Orientation(), p.reach)
+		targeted, front := p.game.Level.CastRay(p.CameraPosition(), p.Orientation(), p.reach)
 		if front != nil {
-			p.blockAction.Do(func() { front.Set(level.STONE) })
+			// the state follows the face of the targeted block the new block is placed against
+			face := front.Position().Sub(targeted.Position())
+			block := level.STONE.WithPlacement(face, p.Orientation())
+			p.blockAction.Do(func() { front.Set(block) })
 		}
 	}
 }
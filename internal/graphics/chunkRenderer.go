@@ -1,28 +1,110 @@
 package graphics
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
+	"math"
+	"slices"
 
 	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/vparent05/minecraft_go/internal/arena"
+	"github.com/vparent05/minecraft_go/internal/frustum"
 	p_game "github.com/vparent05/minecraft_go/internal/game"
 	"github.com/vparent05/minecraft_go/internal/level"
 	"github.com/vparent05/minecraft_go/internal/utils"
-	"github.com/vparent05/minecraft_go/internal/utils/chanx"
 )
 
-type chunkData struct {
-	solidCount       int
+type sectionData struct {
+	solidCount       int // quads
 	transparentCount int
-	solidVBO         uint32
-	transparentVBO   uint32
+	solid            *arena.Allocation // vertices of the mesh in the arena, nil if it is empty
+	transparent      *arena.Allocation
+	visibility       level.SectionVisibility
+
+	// the transparent quads are kept to sort them again when the camera moves to another block
+	transparentVertices []uint32
+	sorted              bool
+	sortedFor           utils.IntVector3 // block of the camera the transparent quads are sorted for
+}
+
+type chunkData struct {
+	sections [level.SECTION_COUNT]sectionData
+}
+
+// bounds returns the box around the sections of the chunk at pos that have quads, ok is false if none has
+func (c *chunkData) bounds(pos utils.IntVector2) (from, to mgl32.Vec3, ok bool) {
+	bottom, top := -1, -1
+	for i, section := range c.sections {
+		if section.solidCount > 0 || section.transparentCount > 0 {
+			if bottom < 0 {
+				bottom = i
+			}
+			top = i
+		}
+	}
+	if bottom < 0 {
+		return from, to, false
+	}
+
+	from = mgl32.Vec3{float32(pos.X * level.CHUNK_WIDTH), float32(level.SectionBottom(bottom)), float32(pos.Y * level.CHUNK_WIDTH)}
+	to = mgl32.Vec3{float32((pos.X + 1) * level.CHUNK_WIDTH), float32(level.SectionBottom(top + 1)), float32((pos.Y + 1) * level.CHUNK_WIDTH)}
+	return from, to, true
+}
+
+func sectionBounds(pos utils.IntVector2, section int) (from, to mgl32.Vec3) {
+	from = mgl32.Vec3{float32(pos.X * level.CHUNK_WIDTH), float32(level.SectionBottom(section)), float32(pos.Y * level.CHUNK_WIDTH)}
+	return from, from.Add(mgl32.Vec3{level.CHUNK_WIDTH, level.SECTION_HEIGHT, level.CHUNK_WIDTH})
+}
+
+// DrawStats counts the chunks of the last frame that were drawn, the ones outside of the view and the ones hidden behind other sections
+type DrawStats struct {
+	DrawnChunks    int
+	CulledChunks   int
+	OccludedChunks int
 }
 
+type visibleChunk struct {
+	pos      utils.IntVector2
+	slot     int
+	sections level.SectionSet
+}
+
+type transparentSection struct {
+	pos      utils.IntVector2
+	slot     int
+	section  int
+	distance float32 // squared distance from the camera to the center of the section
+}
+
+// drawCommand is the layout of the commands read by glMultiDrawElementsIndirect
+type drawCommand struct {
+	count         uint32
+	instanceCount uint32
+	firstIndex    uint32
+	baseVertex    int32
+	baseInstance  uint32 // index of the draw in the draws storage buffer
+}
+
+const drawCommandBytes = 5 * 4
+
 type chunkRenderer struct {
-	game       *p_game.Game
-	program    *program
-	_VAO       uint32
-	chunksData []chunkData
+	game            *p_game.Game
+	program         *program
+	_VAO            uint32
+	_EBO            uint32 // indices of the quads shared by every mesh
+	eboQuads        int    // number of quads _EBO has the indices of
+	vertices        *vertexArena
+	_indirectBuffer uint32
+	_drawsBuffer    uint32 // storage buffer of the chunk coordinates and section y of every command
+	commands        []drawCommand
+	draws           []mgl32.Vec4
+	chunksData      []chunkData
+	slots           map[utils.IntVector2]int // slots of the loaded chunks of the current frame
+	visible         []visibleChunk           // chunks of the current frame in the view
+	transparent     []transparentSection     // sections of the current frame with transparent quads, back to front
+	stats           DrawStats
 }
 
 func NewChunkRenderer(game *p_game.Game) (*chunkRenderer, error) {
@@ -35,9 +117,9 @@ func NewChunkRenderer(game *p_game.Game) (*chunkRenderer, error) {
 		return nil, fmt.Errorf("gl.Init(): %w", err)
 	}
 
-	level.BLOCK_TEXTURE_ATLAS, err = loadTextureAtlas("./textures/blocks", _BLOCKS_TEXTURE, 16)
+	level.BLOCK_TEXTURE_LAYERS, err = loadTextureArray("./textures/blocks", level.BlockTextures(), _BLOCKS_TEXTURE)
 	if err != nil {
-		return nil, fmt.Errorf("loadTextureAtlas(): %w", err)
+		return nil, fmt.Errorf("loadTextureArray(): %w", err)
 	}
 
 	// create the block shader program
@@ -62,87 +144,122 @@ func NewChunkRenderer(game *p_game.Game) (*chunkRenderer, error) {
 	}
 	gl.UniformMatrix4fv(projectionLocation, 1, false, &game.Projection[0]) // TODO separate game from graphic variables
 
-	textureLocation, err := blockProgram.getUniformLocation("atlas")
+	textureLocation, err := blockProgram.getUniformLocation("blockTextures")
 	if err != nil {
 		return nil, fmt.Errorf("getUniformLocation(): %w", err)
 	}
 	gl.Uniform1i(textureLocation, _BLOCKS_TEXTURE)
 
+	// the element buffer binding is part of the state of the VAO
+	var EBO uint32
+	gl.GenBuffers(1, &EBO)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, EBO)
+
+	var indirectBuffer, drawsBuffer uint32
+	gl.GenBuffers(1, &indirectBuffer)
+	gl.GenBuffers(1, &drawsBuffer)
+
 	return &chunkRenderer{
 		game,
 		blockProgram,
 		VAO,
+		EBO,
+		0,
+		newVertexArena(VAO),
+		indirectBuffer,
+		drawsBuffer,
+		nil,
+		nil,
 		make([]chunkData, 33*33), // TODO actually link the render distance to the size of the slice
+		make(map[utils.IntVector2]int),
+		nil,
+		nil,
+		DrawStats{},
 	}, nil
 }
 
-func (r *chunkRenderer) applyMeshUpdate(chunk *level.Chunk) {
-	_, ok := chanx.TryReceive(chunk.MeshUpdates)
-	if ok {
-		newMesh := chunk.Mesh.Load()
-		r.chunksData[chunk.Slot].solidCount = len(newMesh.Solid)
-		r.chunksData[chunk.Slot].transparentCount = len(newMesh.Transparent)
-		r.updateVBOs(chunk, newMesh)
-	}
+func (r *chunkRenderer) Stats() DrawStats {
+	return r.stats
 }
 
-func (r *chunkRenderer) updateVBOs(chunk *level.Chunk, newMesh level.ChunkMesh) {
+// reserveQuads grows the shared element buffer to draw meshes of at least quads quads
+func (r *chunkRenderer) reserveQuads(quads int) {
+	if quads <= r.eboQuads {
+		return
+	}
+	r.eboQuads = max(quads, 2*r.eboQuads)
+	indices := level.QuadIndices(r.eboQuads)
+
 	gl.BindVertexArray(r._VAO)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, r._EBO)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*4, gl.Ptr(indices), gl.STATIC_DRAW)
+}
 
-	chunkData := r.chunksData[chunk.Slot]
+// applyMeshUpdate uploads the meshes of the sections of the chunk that changed since the last frame
+func (r *chunkRenderer) applyMeshUpdate(chunk *level.Chunk) {
+	updated := chunk.TakeMeshUpdates()
+	for i := range level.SECTION_COUNT {
+		if !updated.Has(i) {
+			continue
+		}
 
-	if chunkData.solidVBO == 0 {
-		gl.GenBuffers(1, &chunkData.solidVBO)
-	}
-	gl.BindBuffer(gl.ARRAY_BUFFER, chunkData.solidVBO)
-	vertices := newMesh.Solid
-	if len(vertices) > 0 {
-		gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, gl.Ptr(vertices), gl.STATIC_DRAW)
-	}
+		newMesh := chunk.SectionMesh(i)
+		section := &r.chunksData[chunk.Slot].sections[i]
+		section.solidCount = level.Quads(newMesh.Solid)
+		section.transparentCount = level.Quads(newMesh.Transparent)
+		section.visibility = newMesh.Visibility
+		r.reserveQuads(max(section.solidCount, section.transparentCount))
 
-	if chunkData.transparentVBO == 0 {
-		gl.GenBuffers(1, &chunkData.transparentVBO)
+		r.vertices.release(section.solid)
+		r.vertices.release(section.transparent)
+		section.solid = r.vertices.upload(newMesh.Solid)
+		section.transparent = r.vertices.upload(newMesh.Transparent)
+		section.transparentVertices = slices.Clone(newMesh.Transparent)
+		section.sorted = false
 	}
-	gl.BindBuffer(gl.ARRAY_BUFFER, chunkData.transparentVBO)
-	vertices = newMesh.Transparent
-	if len(vertices) > 0 {
-		gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, gl.Ptr(vertices), gl.STATIC_DRAW)
-	}
-
-	r.chunksData[chunk.Slot] = chunkData
 }
 
-func (r *chunkRenderer) deleteVBOs(chunk *level.Chunk) {
-	chunkData := r.chunksData[chunk.Slot]
-	if chunkData.solidVBO != 0 {
-		gl.DeleteBuffers(1, &chunkData.solidVBO)
-		chunkData.solidVBO = 0
+// sortTransparent sorts the transparent quads of the section again if the camera moved to another block since they were last sorted
+func (r *chunkRenderer) sortTransparent(section *sectionData, pos utils.IntVector2, i int, camera mgl32.Vec3) {
+	cameraBlock := utils.IntVector3{
+		X: int(math.Floor(float64(camera.X()))),
+		Y: int(math.Floor(float64(camera.Y()))),
+		Z: int(math.Floor(float64(camera.Z()))),
 	}
-	if chunkData.transparentVBO != 0 {
-		gl.DeleteBuffers(1, &chunkData.transparentVBO)
-		chunkData.transparentVBO = 0
+	if section.transparent == nil || section.sorted && section.sortedFor == cameraBlock {
+		return
 	}
 
-	r.chunksData[chunk.Slot] = chunkData
+	origin, _ := sectionBounds(pos, i)
+	level.SortQuads(section.transparentVertices, camera.Sub(origin))
+	r.vertices.update(section.transparent, section.transparentVertices)
+	section.sorted = true
+	section.sortedFor = cameraBlock
 }
 
-func (r *chunkRenderer) draw(vbo uint32, pos utils.IntVector2, count int) error {
-	if vbo == 0 {
-		return nil
+// addCommand adds the command drawing the mesh of the section of the chunk at pos
+func (r *chunkRenderer) addCommand(mesh *arena.Allocation, quads int, pos utils.IntVector2, section int) {
+	if mesh == nil || quads == 0 {
+		return
 	}
+	r.commands = append(r.commands, drawCommand{
+		count:         uint32(quads * len(level.QUAD_INDICES)),
+		instanceCount: 1,
+		baseVertex:    int32(mesh.Offset),
+		baseInstance:  uint32(len(r.draws)),
+	})
+	r.draws = append(r.draws, mgl32.Vec4{float32(pos.X), float32(pos.Y), float32(level.SectionBottom(section)), 0})
+}
 
-	chunkCoordinatesLocation, err := r.program.getUniformLocation("chunkCoordinates")
-	if err != nil {
-		return fmt.Errorf("getUniformLocation(): %w", err)
+// uploadCommands replaces the content of the indirect and draws buffers by the commands of the frame
+func (r *chunkRenderer) uploadCommands() {
+	gl.BindBuffer(gl.DRAW_INDIRECT_BUFFER, r._indirectBuffer)
+	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 0, r._drawsBuffer)
+	if len(r.commands) == 0 {
+		return
 	}
-
-	gl.Uniform2fv(chunkCoordinatesLocation, 1, &intVector2ToFloat32Slice(pos)[0])
-
-	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
-	gl.VertexAttribIPointer(0, 1, gl.INT, 4, nil)
-	gl.DrawArrays(gl.TRIANGLES, 0, int32(count))
-
-	return nil
+	gl.BufferData(gl.DRAW_INDIRECT_BUFFER, len(r.commands)*drawCommandBytes, gl.Ptr(r.commands), gl.STREAM_DRAW)
+	gl.BufferData(gl.SHADER_STORAGE_BUFFER, len(r.draws)*4*4, gl.Ptr(r.draws), gl.STREAM_DRAW)
 }
 
 func (r *chunkRenderer) Draw() error {
@@ -154,32 +271,94 @@ func (r *chunkRenderer) Draw() error {
 	gl.UniformMatrix4fv(viewLocation, 1, false, &r.game.View[0]) // TODO separate game from graphic variables
 	gl.BindVertexArray(r._VAO)
 
-	// draw solid geometry
+	clear(r.slots)
 	for pos, chunk := range r.game.Level.Chunks() {
 		r.applyMeshUpdate(chunk)
-		if r.chunksData[chunk.Slot].solidVBO == 0 {
-			r.updateVBOs(chunk, level.ChunkMesh{})
+		r.slots[pos] = chunk.Slot
+	}
+
+	// only the sections in the view that can be seen from the camera are drawn
+	view := frustum.FromMatrix(r.game.Projection.Mul4(r.game.View))
+	visibleSections, occlusion := level.VisibleSections(
+		r.game.Player.CameraPosition(),
+		func(pos utils.IntVector2, section int) (level.SectionVisibility, bool) {
+			slot, ok := r.slots[pos]
+			if !ok {
+				return 0, false
+			}
+			return r.chunksData[slot].sections[section].visibility, true
+		},
+		func(pos utils.IntVector2, section int) bool {
+			return view.IntersectsBox(sectionBounds(pos, section))
+		},
+	)
+
+	r.visible = r.visible[:0]
+	r.stats = DrawStats{}
+	for pos, slot := range r.slots {
+		from, to, ok := r.chunksData[slot].bounds(pos)
+		if !ok {
+			continue
+		}
+		if !view.IntersectsBox(from, to) {
+			r.stats.CulledChunks++
+			continue
 		}
+		sections := ^level.SectionSet(0)
+		if occlusion {
+			sections = visibleSections[pos]
+		}
+		if sections == 0 {
+			r.stats.OccludedChunks++
+			continue
+		}
+		r.stats.DrawnChunks++
+		r.visible = append(r.visible, visibleChunk{pos, slot, sections})
+	}
 
-		err = r.draw(r.chunksData[chunk.Slot].solidVBO, pos, r.chunksData[chunk.Slot].solidCount)
-		if err != nil {
-			return fmt.Errorf("draw(): %w", err)
+	// the solid geometry is drawn first, then the transparent one, each with a single call
+	r.commands = r.commands[:0]
+	r.draws = r.draws[:0]
+	for _, chunk := range r.visible {
+		for i, section := range r.chunksData[chunk.slot].sections {
+			if chunk.sections.Has(i) {
+				r.addCommand(section.solid, section.solidCount, chunk.pos, i)
+			}
 		}
 	}
+	solidCommands := len(r.commands)
 
-	// draw transparent geometry
-	gl.DepthMask(false)
-	defer gl.DepthMask(true)
-	for pos, chunk := range r.game.Level.Chunks() {
-		err = r.draw(r.chunksData[chunk.Slot].transparentVBO, pos, r.chunksData[chunk.Slot].transparentCount)
-		if err != nil {
-			return fmt.Errorf("draw(): %w", err)
+	// transparent quads are blended in the order they are drawn, from the furthest section to the closest
+	camera := r.game.Player.CameraPosition()
+	r.transparent = r.transparent[:0]
+	for _, chunk := range r.visible {
+		for i, section := range r.chunksData[chunk.slot].sections {
+			if !chunk.sections.Has(i) || section.transparentCount == 0 {
+				continue
+			}
+			from, to := sectionBounds(chunk.pos, i)
+			d := from.Add(to).Mul(0.5).Sub(camera)
+			r.transparent = append(r.transparent, transparentSection{chunk.pos, chunk.slot, i, d.Dot(d)})
 		}
 	}
+	slices.SortFunc(r.transparent, func(a, b transparentSection) int {
+		return cmp.Compare(b.distance, a.distance)
+	})
+	for _, t := range r.transparent {
+		section := &r.chunksData[t.slot].sections[t.section]
+		r.sortTransparent(section, t.pos, t.section, camera)
+		r.addCommand(section.transparent, section.transparentCount, t.pos, t.section)
+	}
+	r.uploadCommands()
 
-	return nil
-}
+	if solidCommands > 0 {
+		gl.MultiDrawElementsIndirect(gl.TRIANGLES, gl.UNSIGNED_INT, nil, int32(solidCommands), 0)
+	}
+	if transparentCommands := len(r.commands) - solidCommands; transparentCommands > 0 {
+		gl.DepthMask(false)
+		gl.MultiDrawElementsIndirect(gl.TRIANGLES, gl.UNSIGNED_INT, gl.PtrOffset(solidCommands*drawCommandBytes), int32(transparentCommands), 0)
+		gl.DepthMask(true)
+	}
 
-func intVector2ToFloat32Slice(v utils.IntVector2) []float32 {
-	return []float32{float32(v.X), float32(v.Y)}
+	return nil
 }
@@ -5,11 +5,11 @@ import (
 	"image"
 	"image/draw"
 	"image/png"
-
+	"math/bits"
 	"os"
+	"path/filepath"
 
 	"github.com/go-gl/gl/v4.6-core/gl"
-	"github.com/vparent05/minecraft_go/internal/level"
 )
 
 const (
@@ -31,18 +31,18 @@ func imageToRGBA(img image.Image) *image.RGBA {
 	return rgba
 }
 
-/*
-Takes multiple images of width and height "width" and stitches them together in a grid with "columns" columns
-Returns the stitched image
-*/
-func stitchImages(rows, columns, width int, imgs ...image.Image) *image.RGBA {
-	r := image.Rectangle{image.Point{0, 0}, image.Point{width * columns, width * rows}}
-
-	rgba := image.NewRGBA(r)
+// scaleImage returns img scaled to size by size pixels, every pixel takes the color of the closest one of img
+func scaleImage(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	if bounds.Dx() == size && bounds.Dy() == size {
+		return imageToRGBA(img)
+	}
 
-	for i, img := range imgs {
-		offset := image.Point{(i % columns) * width, (i / columns) * width}
-		draw.Draw(rgba, image.Rect(offset.X, offset.Y, offset.X+width, offset.Y+width), img, image.Point{0, 0}, draw.Src)
+	rgba := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			rgba.Set(x, y, img.At(bounds.Min.X+x*bounds.Dx()/size, bounds.Min.Y+y*bounds.Dy()/size))
+		}
 	}
 	return rgba
 }
@@ -93,49 +93,57 @@ func loadCubemap(path string, id uint32) error {
 }
 
 /*
-Creates and loads the texture atlas in the "id" tray
-"Path" is the path to a folder containing .png files
+Creates and loads the block textures in a texture array in the "id" tray, with their mipmaps
+"Path" is the path to a folder containing the .png files "names", names[i] goes in layer i
+The textures are scaled to the size of the largest one
+Returns the layer of every texture by name
 */
-func loadTextureAtlas(path string, id uint32, resolution int) (map[string]level.BlockId, error) {
-	createTexture(id, gl.TEXTURE_2D)
-
-	textures, err := os.ReadDir(path)
-	if err != nil {
-		return nil, fmt.Errorf("os.ReadDir(): %w", err)
-	}
+func loadTextureArray(path string, names []string, id uint32) (map[string]int, error) {
+	createTexture(id, gl.TEXTURE_2D_ARRAY)
 
-	atlasMap := make(map[string]level.BlockId)
-	images := make([]image.Image, 0)
-	for i, texture := range textures {
-		imgFile, err := os.Open(fmt.Sprintf("%s/%s", path, texture.Name()))
+	images := make([]image.Image, len(names))
+	size := 1
+	for i, name := range names {
+		imgFile, err := os.Open(filepath.Join(path, name))
 		if err != nil {
-			return nil, fmt.Errorf("os.Open(): %w", err)
+			return nil, fmt.Errorf("texture \"%s\": os.Open(): %w", name, err)
 		}
 		img, err := png.Decode(imgFile)
 		imgFile.Close()
 		if err != nil {
-			return nil, fmt.Errorf("image.Decode(): %w", err)
+			return nil, fmt.Errorf("texture \"%s\": image.Decode(): %w", name, err)
 		}
-		atlasMap[texture.Name()] = level.BlockId(i)
-
-		images = append(images, imageToRGBA(img))
+		images[i] = img
+		size = max(size, img.Bounds().Dx(), img.Bounds().Dy())
 	}
 
-	atlasImage := stitchImages(16, 16, resolution, images...)
-
-	gl.TexImage2D(
-		gl.TEXTURE_2D,
-		0,
-		gl.RGBA,
-		int32(atlasImage.Rect.Size().X),
-		int32(atlasImage.Rect.Size().Y),
-		0,
-		gl.RGBA,
-		gl.UNSIGNED_BYTE,
-		gl.Ptr(atlasImage.Pix),
-	)
+	// every mipmap level down to 1 by 1 pixel
+	gl.TexStorage3D(gl.TEXTURE_2D_ARRAY, int32(bits.Len(uint(size))), gl.RGBA8, int32(size), int32(size), int32(len(names)))
+	layers := make(map[string]int, len(names))
+	for i, img := range images {
+		rgba := scaleImage(img, size)
+		gl.TexSubImage3D(
+			gl.TEXTURE_2D_ARRAY,
+			0,
+			0,
+			0,
+			int32(i),
+			int32(size),
+			int32(size),
+			1,
+			gl.RGBA,
+			gl.UNSIGNED_BYTE,
+			gl.Ptr(rgba.Pix),
+		)
+		layers[names[i]] = i
+	}
+	gl.GenerateMipmap(gl.TEXTURE_2D_ARRAY)
 
-	setTextureInterpolation(gl.TEXTURE_2D, gl.NEAREST)
+	// the pixels stay sharp up close, the mipmaps blend them at a distance
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.REPEAT)
 
-	return atlasMap, nil
+	return layers, nil
 }
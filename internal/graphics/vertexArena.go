@@ -0,0 +1,82 @@
+package graphics
+
+import (
+	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/vparent05/minecraft_go/internal/arena"
+	"github.com/vparent05/minecraft_go/internal/level"
+)
+
+const vertexBytes = level.VERTEX_SIZE * 4
+
+// initialArenaVertices is the number of vertices the arena can hold before it first grows
+const initialArenaVertices = 1 << 20
+
+// vertexArena holds the meshes of every section in a single vertex buffer, sub-allocated in vertices
+type vertexArena struct {
+	arena *arena.Arena
+	_VAO  uint32
+	_VBO  uint32
+}
+
+func newVertexArena(VAO uint32) *vertexArena {
+	a := &vertexArena{arena.New(0), VAO, 0}
+	a.resize(initialArenaVertices)
+	return a
+}
+
+// upload copies the vertices to a new allocation, nil if there are none
+func (a *vertexArena) upload(vertices []uint32) *arena.Allocation {
+	count := len(vertices) / level.VERTEX_SIZE
+	if count == 0 {
+		return nil
+	}
+
+	allocation, ok := a.arena.Alloc(count)
+	if !ok {
+		// compacting is enough when the free vertices are only scattered, otherwise the buffer grows too
+		size := a.arena.Size()
+		for size-a.arena.Used() < count {
+			size *= 2
+		}
+		a.resize(size)
+		allocation, _ = a.arena.Alloc(count)
+	}
+
+	a.update(allocation, vertices)
+	return allocation
+}
+
+// update replaces the vertices of the allocation by as many vertices
+func (a *vertexArena) update(allocation *arena.Allocation, vertices []uint32) {
+	gl.BindBuffer(gl.ARRAY_BUFFER, a._VBO)
+	gl.BufferSubData(gl.ARRAY_BUFFER, allocation.Offset*vertexBytes, len(vertices)*4, gl.Ptr(vertices))
+}
+
+func (a *vertexArena) release(allocation *arena.Allocation) {
+	if allocation != nil {
+		a.arena.Release(allocation)
+	}
+}
+
+// resize replaces the buffer by a compacted one of size vertices
+func (a *vertexArena) resize(size int) {
+	moves, _ := a.arena.Compact(size)
+
+	var VBO uint32
+	gl.GenBuffers(1, &VBO)
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, VBO)
+	gl.BufferData(gl.COPY_WRITE_BUFFER, size*vertexBytes, nil, gl.DYNAMIC_DRAW)
+	if a._VBO != 0 {
+		gl.BindBuffer(gl.COPY_READ_BUFFER, a._VBO)
+		for _, move := range moves {
+			gl.CopyBufferSubData(gl.COPY_READ_BUFFER, gl.COPY_WRITE_BUFFER, move.From*vertexBytes, move.To*vertexBytes, move.Size*vertexBytes)
+		}
+		gl.DeleteBuffers(1, &a._VBO)
+	}
+	a._VBO = VBO
+
+	// the vertex attributes of the VAO point to the buffer
+	gl.BindVertexArray(a._VAO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, a._VBO)
+	gl.VertexAttribIPointer(0, level.VERTEX_SIZE, gl.INT, vertexBytes, nil)
+}
@@ -0,0 +1,86 @@
+package level
+
+import "github.com/vparent05/minecraft_go/internal/utils"
+
+// maxOcclusion is the ambient occlusion of the darkest vertices, in the corner between 2 blocks
+const maxOcclusion = 3
+
+// blockOccluders holds which of the blocks around a block are opaque, the one at offset o from it in bit (o.X+1)*9 + (o.Y+1)*3 + o.Z+1
+type blockOccluders uint32
+
+func occluderBit(offset utils.IntVector3) blockOccluders {
+	return 1 << ((offset.X+1)*9 + (offset.Y+1)*3 + offset.Z + 1)
+}
+
+func (o *blockOccluders) add(offset utils.IntVector3) {
+	*o |= occluderBit(offset)
+}
+
+func (o blockOccluders) has(offset utils.IntVector3) bool {
+	return o&occluderBit(offset) != 0
+}
+
+// quadOcclusion is the ambient occlusion of every vertex of a quad, from 0 for an open vertex to maxOcclusion
+type quadOcclusion [QUAD_VERTICES]int
+
+// vertexOcclusion returns the ambient occlusion of a vertex given the blocks in front of its face touching it: the 2 along its sides and the one in its corner
+func vertexOcclusion(side1, side2, corner bool) int {
+	if side1 && side2 {
+		return maxOcclusion // the corner is hidden behind the sides
+	}
+	occlusion := 0
+	for _, occluded := range [3]bool{side1, side2, corner} {
+		if occluded {
+			occlusion++
+		}
+	}
+	return occlusion
+}
+
+// towards returns the direction of the side of a block closest to a coordinate in model units
+func towards(coordinate int) int {
+	if 2*coordinate < modelUnits {
+		return -1
+	}
+	return 1
+}
+
+// occluded returns true if the blocks around darken the vertices of the quad, only the quads on the sides of their block are
+func (q modelQuad) occluded() bool {
+	return q.face < faceNone && q.cullface >= 0
+}
+
+// cornerBlocks returns the offsets from its block of the blocks of the layer in front of the face of q touching the corner of the side vertex is closest to: the 2 along its sides and the one in its corner
+func (q modelQuad) cornerBlocks(vertex modelVertex) (sideA, sideB, corner utils.IntVector3) {
+	axes := faceAxes[q.face]
+	sideA, sideB = faceNormals[q.face], faceNormals[q.face]
+	setComponent(&sideA, axes[0], towards(component(vertex.position, axes[0])))
+	setComponent(&sideB, axes[1], towards(component(vertex.position, axes[1])))
+	corner = sideA
+	setComponent(&corner, axes[1], component(sideB, axes[1]))
+	return sideA, sideB, corner
+}
+
+/*
+occlusion returns the ambient occlusion of the vertices of q, a quad on a side of a block whose neighbours are o.
+Every vertex is darkened by the blocks of the layer in front of the face touching the corner of the side it is closest to.
+*/
+func (q modelQuad) occlusion(o blockOccluders) quadOcclusion {
+	var occlusion quadOcclusion
+	for i, vertex := range q.vertices {
+		sideA, sideB, corner := q.cornerBlocks(vertex)
+		occlusion[i] = vertexOcclusion(o.has(sideA), o.has(sideB), o.has(corner))
+	}
+	return occlusion
+}
+
+/*
+firstVertex returns the vertex the quad is appended from, so that the triangles of QUAD_INDICES split it along the diagonal between its least occluded corners.
+Otherwise the shade of a single dark corner would stretch along the diagonal to the opposite one, and look different depending on the corner.
+*/
+func (o quadOcclusion) firstVertex() int {
+	if o[0]+o[2] > o[1]+o[3] {
+		return 1
+	}
+	return 0
+}
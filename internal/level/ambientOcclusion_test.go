@@ -0,0 +1,156 @@
+package level
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+// meshTestBlocks returns the mesh of the section of y = 0 of a chunk holding a stone floor at y = 0 and the blocks of pillars above it
+func meshTestBlocks(pillars ...utils.IntVector3) []uint32 {
+	l, m := newTestLevel(1)
+	c := addTestChunk(l, m, utils.IntVector2{})
+	var blocks chunkBlocks
+	for x := range CHUNK_WIDTH {
+		for z := range CHUNK_WIDTH {
+			blocks[x][-MIN_Y][z] = STONE
+		}
+	}
+	for _, p := range pillars {
+		blocks[p.X][p.Y-MIN_Y][p.Z] = STONE
+	}
+	c.setBlocks(c.getCoordinates(), STATUS_FULL, &blocks, false)
+	c.generateMesh(l)
+
+	section, _ := sectionOf(0)
+	return c.SectionMesh(section).Solid
+}
+
+// floorOcclusion returns the ambient occlusion of the vertices of the top of the floor, by their x and z in blocks
+func floorOcclusion(t *testing.T, mesh []uint32) map[utils.IntVector2]int {
+	t.Helper()
+	occlusion := make(map[utils.IntVector2]int)
+	for i := 0; i < len(mesh); i += VERTEX_SIZE {
+		p := vertexPosition(mesh[i])
+		if int(mesh[i]&0xF) != faceTop || p.Y() != 1 {
+			continue
+		}
+		corner := utils.IntVector2{X: int(p.X()), Y: int(p.Z())}
+		value := int(mesh[i+2] >> 16 & 0x3)
+		if seen, ok := occlusion[corner]; ok && seen != value {
+			t.Fatalf("the vertices of the floor at %v have the ambient occlusions %d and %d", corner, seen, value)
+		}
+		occlusion[corner] = value
+	}
+	return occlusion
+}
+
+func TestAmbientOcclusionAroundPillar(t *testing.T) {
+	mesh := meshTestBlocks(utils.IntVector3{X: 7, Y: 1, Z: 7})
+
+	// the floor darkens at the corners of the pillar, where the vertices touch it
+	for corner, value := range floorOcclusion(t, mesh) {
+		want := 0
+		if (corner.X == 7 || corner.X == 8) && (corner.Y == 7 || corner.Y == 8) {
+			want = 1
+		}
+		if value != want {
+			t.Errorf("the floor at %v has the ambient occlusion %d, want %d", corner, value, want)
+		}
+	}
+
+	// the sides of the pillar darken at their bottom, against the floor in front and in the corners
+	for i := 0; i < len(mesh); i += VERTEX_SIZE {
+		face := int(mesh[i] & 0xF)
+		p := vertexPosition(mesh[i])
+		if face == faceTop || face == faceBottom || p.Y() < 1 {
+			continue
+		}
+		want := 0
+		if p.Y() == 1 {
+			want = 2
+		}
+		if value := int(mesh[i+2] >> 16 & 0x3); value != want {
+			t.Errorf("the side %d of the pillar at %v has the ambient occlusion %d, want %d", face, p, value, want)
+		}
+	}
+}
+
+func TestAmbientOcclusionInCorner(t *testing.T) {
+	// the floor between 2 pillars touching by a corner is hidden from both sides
+	mesh := meshTestBlocks(utils.IntVector3{X: 7, Y: 1, Z: 6}, utils.IntVector3{X: 6, Y: 1, Z: 7})
+	occlusion := floorOcclusion(t, mesh)
+	if value := occlusion[utils.IntVector2{X: 7, Y: 7}]; value != maxOcclusion {
+		t.Errorf("the floor in the corner has the ambient occlusion %d, want %d", value, maxOcclusion)
+	}
+	if value := occlusion[utils.IntVector2{X: 8, Y: 8}]; value != 0 {
+		t.Errorf("the floor away from the corner has the ambient occlusion %d, want 0", value)
+	}
+}
+
+func TestAmbientOcclusionFlipsDiagonal(t *testing.T) {
+	mesh := meshTestBlocks(utils.IntVector3{X: 7, Y: 1, Z: 7})
+
+	// the floor diagonal to the pillar has a single dark corner, the triangles split the quad along the diagonal away from it
+	single := 0
+	for i := 0; i < len(mesh); i += QUAD_VERTICES * VERTEX_SIZE {
+		if int(mesh[i]&0xF) != faceTop || vertexPosition(mesh[i]).Y() != 1 {
+			continue
+		}
+		var occlusion quadOcclusion
+		var positions [QUAD_VERTICES]mgl32.Vec3
+		for j := range QUAD_VERTICES {
+			occlusion[j] = int(mesh[i+j*VERTEX_SIZE+2] >> 16 & 0x3)
+			positions[j] = vertexPosition(mesh[i+j*VERTEX_SIZE])
+		}
+		if occlusion[0]+occlusion[1]+occlusion[2]+occlusion[3] != 1 {
+			continue
+		}
+		// QUAD_INDICES split the quads along the diagonal of their vertices 0 and 2
+		if occlusion[0] == 1 || occlusion[2] == 1 {
+			t.Errorf("the quad of the floor at %v is split along the diagonal of its dark corner", positions)
+		}
+		single++
+	}
+	if single != 4 {
+		t.Errorf("%d quads of the floor have a single dark corner, want the 4 diagonal to the pillar", single)
+	}
+}
+
+func TestCornerEditRemeshesDiagonalChunk(t *testing.T) {
+	l, m := newTestLevel(1)
+	c := addTestChunk(l, m, utils.IntVector2{X: 0, Y: 0})
+	diagonal := addTestChunk(l, m, utils.IntVector2{X: 1, Y: 1})
+	side := addTestChunk(l, m, utils.IntVector2{X: 1, Y: 0})
+
+	var floor, empty chunkBlocks
+	for x := range CHUNK_WIDTH {
+		for z := range CHUNK_WIDTH {
+			floor[x][-MIN_Y][z] = STONE
+		}
+	}
+	c.setBlocks(c.getCoordinates(), STATUS_FULL, &floor, false)
+	diagonal.setBlocks(diagonal.getCoordinates(), STATUS_FULL, &empty, false)
+	side.setBlocks(side.getCoordinates(), STATUS_FULL, &empty, false)
+	c.generateMesh(l)
+	c.TakeMeshUpdates()
+
+	// the block touches the corner of the floor diagonally, across the corner of the chunk
+	section, _ := sectionOf(0)
+	diagonal.setBlock(utils.IntVector3{X: 0, Y: 1, Z: 0}, STONE)
+	c.generateMesh(l)
+	if updated := c.TakeMeshUpdates(); !updated.Has(section) {
+		t.Fatalf("updated meshes are %b, want section %d", updated, section)
+	}
+	occlusion := floorOcclusion(t, c.SectionMesh(section).Solid)
+	if value := occlusion[utils.IntVector2{X: CHUNK_WIDTH, Y: CHUNK_WIDTH}]; value != 1 {
+		t.Errorf("the floor in the corner of the chunk has the ambient occlusion %d, want 1", value)
+	}
+
+	// a block on the bottom of a section of the side chunk shows in the meshes of both sections next to it
+	side.setBlock(utils.IntVector3{X: 0, Y: SectionBottom(section + 1), Z: 7}, STONE)
+	if want := SectionSet(1<<section | 1<<(section+1)); c.dirtySections != want {
+		t.Errorf("dirty sections are %b, want %b", c.dirtySections, want)
+	}
+}
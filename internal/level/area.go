@@ -0,0 +1,93 @@
+package level
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+/*
+Area holds the generated chunks of a rectangle of the level, without any observer, mesh or storage.
+The chunks go through the same stages as in a Level, except light,
+and the features spilling between chunks of the area are merged the same way,
+so the blocks of an area match the ones of a level generated with the same generator.
+*/
+type Area struct {
+	from   utils.IntVector2 // coordinates of the first chunk
+	width  int              // chunks along x
+	depth  int              // chunks along z
+	chunks []chunkStorage
+}
+
+// GenerateArea generates the chunks from chunk coordinates from to to included, using every CPU
+func GenerateArea(generator Generator, from, to utils.IntVector2) *Area {
+	a := &Area{
+		from:  from,
+		width: to.X - from.X + 1,
+		depth: to.Y - from.Y + 1,
+	}
+	a.chunks = make([]chunkStorage, a.width*a.depth)
+	outside := make([]map[utils.IntVector2][]blockWrite, len(a.chunks))
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				coordinates := utils.IntVector2{X: from.X + i%a.width, Y: from.Y + i/a.width}
+				blocks := generator.GenerateTerrain(coordinates)
+				if c, ok := generator.(carver); ok {
+					c.carve(coordinates, blocks)
+				}
+				if p, ok := generator.(featurePlacer); ok {
+					outside[i] = p.placeFeatures(coordinates, blocks)
+				}
+				a.chunks[i] = newChunkStorage(blocks)
+			}
+		}()
+	}
+	for i := range a.chunks {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for _, writes := range outside {
+		for target, w := range writes {
+			if blocks := a.chunk(target); blocks != nil {
+				blocks.mergeWrites(w)
+			}
+		}
+	}
+	return a
+}
+
+func (a *Area) chunk(coordinates utils.IntVector2) *chunkStorage {
+	d := coordinates.Sub(a.from)
+	if d.X < 0 || d.Y < 0 || d.X >= a.width || d.Y >= a.depth {
+		return nil
+	}
+	return &a.chunks[d.X+d.Y*a.width]
+}
+
+// Block returns the block at level coordinates x, y, z, AIR outside of the area
+func (a *Area) Block(x, y, z int) BlockId {
+	blocks := a.chunk(utils.IntVector2{X: floorDiv(x, CHUNK_WIDTH), Y: floorDiv(z, CHUNK_WIDTH)})
+	if blocks == nil || y < MIN_Y || y >= MAX_Y {
+		return AIR
+	}
+	return blocks.get(utils.Mod(x, CHUNK_WIDTH), y, utils.Mod(z, CHUNK_WIDTH))
+}
+
+// Top returns the height and the block of the highest non air block of the column at x, z, y is below MIN_Y if there is none
+func (a *Area) Top(x, z int) (y int, b BlockId) {
+	for y := MAX_Y - 1; y >= MIN_Y; y-- {
+		if b := a.Block(x, y, z); b != AIR {
+			return y, b
+		}
+	}
+	return MIN_Y - 1, AIR
+}
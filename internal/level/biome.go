@@ -0,0 +1,231 @@
+package level
+
+import (
+	"math"
+	"slices"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+type BiomeId uint8
+
+const (
+	OCEAN BiomeId = iota
+	FROZEN_OCEAN
+	BEACH
+	PLAINS
+	FOREST
+	SWAMP
+	DESERT
+	TUNDRA
+	MOUNTAINS
+)
+
+const WATER_LEVEL = 60
+
+// climateRange is the [min, max] interval of a climate parameter in which a biome is dominant
+type climateRange [2]float32
+
+var anyClimate = climateRange{-math.MaxFloat32, math.MaxFloat32}
+
+type biome struct {
+	name string
+
+	temperature     climateRange
+	humidity        climateRange
+	continentalness climateRange
+
+	height      func(n float32) float32 // terrain height from the heightmap noise n in [-1, 1]
+	surface     BlockId                 // top block above water level
+	filler      BlockId                 // blocks right below the surface
+	fillerDepth int
+	seabed      BlockId // top and filler blocks under water level
+	frozen      bool    // the water surface turns to ice
+	overhang    float32 // amplitude in blocks of the 3D noise added to the heightmap, creating cliffs and overhangs
+	caveDensity float32 // multiplier of the amount of caves, 0 disables them
+	features    []featurePlacement
+}
+
+var grassGround, sandGround, snowGround, rockGround []BlockId
+
+// BIOMES is ordered by priority: when a climate falls in several biomes, the first one is dominant
+var BIOMES []biome
+
+// loadBiomes is called by LoadBlocks once the ids of the blocks used by the biomes are known
+func loadBiomes() {
+	grassGround = []BlockId{GRASS, DIRT}
+	sandGround = []BlockId{SAND}
+	snowGround = []BlockId{SNOW, DIRT, GRASS}
+	rockGround = []BlockId{STONE, GRAVEL, GRASS, SNOW}
+
+	BIOMES = []biome{
+		OCEAN: {
+			"ocean",
+			climateRange{-0.4, math.MaxFloat32}, anyClimate, climateRange{-math.MaxFloat32, -0.25},
+			func(n float32) float32 { return 42 + 10*n },
+			SAND, SAND, 4, GRAVEL, false, 4, 0.6,
+			nil,
+		},
+		FROZEN_OCEAN: {
+			"frozen_ocean",
+			anyClimate, anyClimate, climateRange{-math.MaxFloat32, -0.25},
+			func(n float32) float32 { return 42 + 10*n },
+			SNOW, DIRT, 4, GRAVEL, true, 4, 0.6,
+			nil,
+		},
+		BEACH: {
+			"beach",
+			climateRange{-0.4, math.MaxFloat32}, anyClimate, climateRange{-0.25, -0.15},
+			func(n float32) float32 { return 61 + 3*n },
+			SAND, SAND, 4, SAND, false, 2, 0.3,
+			nil,
+		},
+		PLAINS: {
+			"plains",
+			climateRange{-0.4, 0.4}, climateRange{-math.MaxFloat32, 0.1}, climateRange{-0.15, 0.45},
+			func(n float32) float32 { return 66 + 8*n },
+			GRASS, DIRT, 4, SAND, false, 4, 1,
+			[]featurePlacement{
+				{oakTree{4, 6}, 0.002, grassGround, 0},
+				{boulder{STONE, 2}, 0.0005, grassGround, 2},
+				{plant{TALL_GRASS}, 0.15, grassGround, 0},
+			},
+		},
+		FOREST: {
+			"forest",
+			climateRange{-0.4, math.MaxFloat32}, climateRange{0.1, 0.4}, climateRange{-0.15, 0.45},
+			func(n float32) float32 { return 68 + 14*n },
+			GRASS, DIRT, 4, SAND, false, 6, 1,
+			[]featurePlacement{
+				{oakTree{4, 7}, 0.035, grassGround, 0},
+				{plant{TALL_GRASS}, 0.08, grassGround, 0},
+			},
+		},
+		SWAMP: {
+			"swamp",
+			climateRange{-0.4, math.MaxFloat32}, climateRange{0.4, math.MaxFloat32}, climateRange{-0.15, 0.45},
+			func(n float32) float32 { return 61 + 2*n },
+			GRASS, DIRT, 3, DIRT, false, 2, 0.5,
+			[]featurePlacement{
+				{oakTree{3, 5}, 0.01, grassGround, 0},
+				{plant{TALL_GRASS}, 0.2, grassGround, 0},
+			},
+		},
+		DESERT: {
+			"desert",
+			climateRange{0.4, math.MaxFloat32}, climateRange{-math.MaxFloat32, 0.1}, climateRange{-0.15, 0.45},
+			func(n float32) float32 { return 66 + 6*n },
+			SAND, SAND, 6, SAND, false, 3, 0.8,
+			[]featurePlacement{
+				{columnFeature{CACTUS, 3}, 0.004, sandGround, 0},
+				{boulder{STONE, 1}, 0.001, sandGround, 1},
+			},
+		},
+		TUNDRA: {
+			"tundra",
+			climateRange{-math.MaxFloat32, -0.4}, anyClimate, climateRange{-0.25, 0.45},
+			func(n float32) float32 { return 67 + 10*n },
+			SNOW, DIRT, 3, GRAVEL, true, 5, 1,
+			[]featurePlacement{
+				{spruceTree{6, 9}, 0.008, snowGround, 0},
+			},
+		},
+		MOUNTAINS: {
+			"mountains",
+			anyClimate, anyClimate, climateRange{0.45, math.MaxFloat32},
+			func(n float32) float32 { return 80 + 90*float32(math.Abs(float64(n))) },
+			STONE, STONE, 1, GRAVEL, false, 24, 1.4,
+			[]featurePlacement{
+				{spruceTree{5, 8}, 0.004, rockGround, 0},
+				{boulder{STONE, 2}, 0.003, rockGround, 2},
+			},
+		},
+	}
+}
+
+func (b BiomeId) Name() string {
+	return BIOMES[b].name
+}
+
+// BiomeSource is implemented by generators that place biomes
+type BiomeSource interface {
+	Biome(x, z int) BiomeId
+}
+
+type climate struct {
+	temperature     float32
+	humidity        float32
+	continentalness float32
+}
+
+type biomeWeight struct {
+	id     BiomeId
+	weight float32
+}
+
+// climateMap samples independent temperature, humidity and continentalness noise maps
+type climateMap struct {
+	temperature     *utils.Noise
+	humidity        *utils.Noise
+	continentalness *utils.Noise
+}
+
+// Distance in climate space over which neighbouring biomes blend
+const biomeBlendDistance = 0.08
+
+func newClimateMap(seed int64) *climateMap {
+	return &climateMap{
+		temperature:     utils.NewNoise(utils.DeriveSeed(seed, "temperature")),
+		humidity:        utils.NewNoise(utils.DeriveSeed(seed, "humidity")),
+		continentalness: utils.NewNoise(utils.DeriveSeed(seed, "continentalness")),
+	}
+}
+
+func (m *climateMap) climate(x, z int) climate {
+	return climate{
+		temperature:     1.5 * m.temperature.ScaledFractalNoise2(float32(x), float32(z), 0.0012, 4),
+		humidity:        1.5 * m.humidity.ScaledFractalNoise2(float32(x), float32(z), 0.0012, 4),
+		continentalness: 1.5 * m.continentalness.ScaledFractalNoise2(float32(x), float32(z), 0.0008, 5),
+	}
+}
+
+func (r climateRange) distance(v float32) float32 {
+	return max(r[0]-v, v-r[1], 0)
+}
+
+// weights returns the normalized influence of every biome close to c, sorted from the dominant biome down
+func (c climate) weights() []biomeWeight {
+	weights := make([]biomeWeight, 0, len(BIOMES))
+	var total float32
+	for id, b := range BIOMES {
+		dt := b.temperature.distance(c.temperature)
+		dh := b.humidity.distance(c.humidity)
+		dc := b.continentalness.distance(c.continentalness)
+		d := float64(dt*dt+dh*dh+dc*dc) / (biomeBlendDistance * biomeBlendDistance)
+		if d > 9 {
+			continue
+		}
+
+		w := float32(math.Exp(-d))
+		weights = append(weights, biomeWeight{BiomeId(id), w})
+		total += w
+	}
+
+	for i := range weights {
+		weights[i].weight /= total
+	}
+	// stable to keep the priority order between biomes of equal weight
+	slices.SortStableFunc(weights, func(a, b biomeWeight) int {
+		if a.weight > b.weight {
+			return -1
+		} else if a.weight < b.weight {
+			return 1
+		}
+		return 0
+	})
+	return weights
+}
+
+func (m *climateMap) Biome(x, z int) BiomeId {
+	return m.climate(x, z).weights()[0].id
+}
@@ -1,210 +1,410 @@
 package level
 
-type BlockId uint8
-
-const (
-	AIR BlockId = iota
-	GRASS
-	GLASS
-	WATER
-	SAND
-	DIRT
-	STONE
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+type BlockId uint32
+
+// AIR is the only block that isn't defined in the block registry, its id is always 0
+const AIR BlockId = 0
+
+// Blocks used by the world generators, their ids are assigned by LoadBlocks
+var (
+	GRASS       BlockId
+	WATER       BlockId
+	SAND        BlockId
+	DIRT        BlockId
+	STONE       BlockId
+	SNOW        BlockId
+	ICE         BlockId
+	GRAVEL      BlockId
+	LOG         BlockId
+	LEAVES      BlockId
+	CACTUS      BlockId
+	TALL_GRASS  BlockId
+	COAL_ORE    BlockId
+	IRON_ORE    BlockId
+	COPPER_ORE  BlockId
+	GOLD_ORE    BlockId
+	DIAMOND_ORE BlockId
 )
 
+var builtinBlocks = map[string]*BlockId{
+	"grass":       &GRASS,
+	"water":       &WATER,
+	"sand":        &SAND,
+	"dirt":        &DIRT,
+	"stone":       &STONE,
+	"snow":        &SNOW,
+	"ice":         &ICE,
+	"gravel":      &GRAVEL,
+	"log":         &LOG,
+	"leaves":      &LEAVES,
+	"cactus":      &CACTUS,
+	"tall_grass":  &TALL_GRASS,
+	"coal_ore":    &COAL_ORE,
+	"iron_ore":    &IRON_ORE,
+	"copper_ore":  &COPPER_ORE,
+	"gold_ore":    &GOLD_ORE,
+	"diamond_ore": &DIAMOND_ORE,
+}
+
 type blockType struct {
 	name          string
-	height        int
 	isTransparent bool
 	isLiquid      bool
 	viscosity     float32
-	textureRight  string
-	textureLeft   string
-	textureTop    string
-	textureBottom string
-	textureFront  string
-	textureBack   string
+	properties    []blockProperty
+	models        []blockModel // indexed by state
+	lights        []light      // colored light emitted, indexed by state
+	absorption    light        // colored light dimmed going through the block, on top of the 1 of every block
 }
 
-var BLOCK_TEXTURE_ATLAS map[string]BlockId
+// BLOCK_TEXTURE_LAYERS is the layer of every texture of BlockTextures in the texture array of the renderer, by name
+var BLOCK_TEXTURE_LAYERS map[string]int
 
 var BLOCK_TYPES map[BlockId]blockType
 
-const atlasWidth = 16
-
-func LoadBlocks() {
-	BLOCK_TYPES = map[BlockId]blockType{
-		GRASS: {
-			"grass",
-			15,
-			false,
-			false,
-			1.0,
-			"grass_side.png",
-			"grass_side.png",
-			"grass_top.png",
-			"grass_bottom.png",
-			"grass_side.png",
-			"grass_side.png",
-		},
-		GLASS: {
-			"glass",
-			15,
-			true,
-			false,
-			1.0,
-			"glass.png",
-			"glass.png",
-			"glass.png",
-			"glass.png",
-			"glass.png",
-			"glass.png",
-		},
-		WATER: {
-			"water",
-			13,
-			true,
-			true,
-			0.5,
-			"water.png",
-			"water.png",
-			"water.png",
-			"water.png",
-			"water.png",
-			"water.png",
-		},
-		SAND: {
-			"sand",
-			15,
-			false,
-			false,
-			1.0,
-			"sand.png",
-			"sand.png",
-			"sand.png",
-			"sand.png",
-			"sand.png",
-			"sand.png",
-		},
-		DIRT: {
-			"dirt",
-			15,
-			false,
-			false,
-			1.0,
-			"dirt.png",
-			"dirt.png",
-			"dirt.png",
-			"dirt.png",
-			"dirt.png",
-			"dirt.png",
-		},
-		STONE: {
-			"stone",
-			15,
-			false,
-			false,
-			1.0,
-			"stone.png",
-			"stone.png",
-			"stone.png",
-			"stone.png",
-			"stone.png",
-			"stone.png",
-		},
-	}
-}
-
-func (b BlockId) mesh(x, y, z int, render [6]bool) []uint32 {
-	// one vertex is encoded as: x (4bits) | y (8bits) | z (4bits) | orientation (4bits) | texture coordinate (x + atlasWidth*y) (8bits) | height (real height: (height+1) / 16) (4bits)
-	if b == AIR {
-		return []uint32{}
+var blockIds map[string]BlockId
+
+/*
+blockDefinition is a block of the registry file, a JSON array of:
+
+	{
+		"name": "furnace",
+		"model": "cube",        // optional, file name of the model in the models directory without .json, cube by default
+		"height": 15,           // optional, the model is squashed to a height of (height+1) / 16, 15 by default
+		"transparent": false,   // optional
+		"liquid": false,        // optional
+		"viscosity": 1.0,       // optional, 1 by default
+		"light": [0, 0, 0],     // optional, red, green and blue light emitted from 0 to 15, none by default
+		"filter": [15, 15, 15], // optional, red, green and blue light let through a transparent block from 0 to 15, all of it by default
+		"textures": {"all": "furnace_top.png", "side": "furnace_side.png", "front": "furnace_front.png"},
+		"properties": [{"name": "facing", "values": ["south", "west", "north", "east"]}, {"name": "lit", "values": ["false", "true"]}], // optional
+		"variants": [{"when": {"lit": "true"}, "light": [13, 11, 8], "textures": {"front": "furnace_front_lit.png"}}]                    // optional
+	}
+
+Textures are file names in the block texture directory. "all" applies to every face and "side" to the 4 vertical faces,
+they are overridden by "top", "bottom", "left", "right", "front" and "back".
+The first value of a property is its default. The "axis" (y, x, z) and "facing" (south, west, north, east) properties rotate the model,
+the others only change the block through the variants, which override the model, the textures, the height and the light of the states they match, in order.
+*/
+type blockDefinition struct {
+	Name        string               `json:"name"`
+	Model       string               `json:"model"`
+	Height      *int                 `json:"height"`
+	Transparent bool                 `json:"transparent"`
+	Liquid      bool                 `json:"liquid"`
+	Viscosity   *float32             `json:"viscosity"`
+	Light       [3]int               `json:"light"`
+	Filter      *[3]int              `json:"filter"`
+	Textures    map[string]string    `json:"textures"`
+	Properties  []propertyDefinition `json:"properties"`
+	Variants    []variantDefinition  `json:"variants"`
+}
+
+type propertyDefinition struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type variantDefinition struct {
+	When     map[string]string `json:"when"`
+	Model    string            `json:"model"`
+	Height   *int              `json:"height"`
+	Light    *[3]int           `json:"light"`
+	Textures map[string]string `json:"textures"`
+}
+
+const defaultModel = "cube"
+
+// models returns the name of every model the block uses
+func (d *blockDefinition) models() []string {
+	models := []string{cmp.Or(d.Model, defaultModel)}
+	for _, v := range d.Variants {
+		if v.Model != "" {
+			models = append(models, v.Model)
+		}
+	}
+	return models
+}
+
+var faceNames = []string{"all", "side", "top", "bottom", "left", "right", "front", "back"}
+
+func faceTextures(textures map[string]string) [6]string {
+	texture := func(faces ...string) string {
+		for _, face := range faces {
+			if t, ok := textures[face]; ok {
+				return t
+			}
+		}
+		return ""
+	}
+
+	return [6]string{
+		faceTop:    texture("top", "all"),
+		faceBottom: texture("bottom", "all"),
+		faceLeft:   texture("left", "side", "all"),
+		faceRight:  texture("right", "side", "all"),
+		faceFront:  texture("front", "side", "all"),
+		faceBack:   texture("back", "side", "all"),
+	}
+}
+
+// blockType builds the type defined by d, shapes holds the models it uses
+func (d *blockDefinition) blockType(shapes map[string]blockShape) (blockType, error) {
+	t := blockType{
+		name:          d.Name,
+		isTransparent: d.Transparent,
+		isLiquid:      d.Liquid,
+		viscosity:     1.0,
+	}
+	if d.Viscosity != nil {
+		t.viscosity = *d.Viscosity
+	}
+	if d.Filter != nil {
+		if !validLight(*d.Filter) {
+			return t, fmt.Errorf("filter %v out of [0, %d]", *d.Filter, MAX_LIGHT)
+		}
+		t.absorption = colorLight([3]int{MAX_LIGHT - d.Filter[0], MAX_LIGHT - d.Filter[1], MAX_LIGHT - d.Filter[2]})
 	}
 
-	mesh := make([]uint32, 0, 36)
+	shift := 0
+	for _, p := range d.Properties {
+		if len(p.Values) == 0 {
+			return t, fmt.Errorf("property \"%s\" has no value", p.Name)
+		}
+		rotations := map[string]map[string]blockRotation{"axis": axisRotations, "facing": facingRotations}[p.Name]
+		for _, v := range p.Values {
+			if _, ok := rotations[v]; rotations != nil && !ok {
+				return t, fmt.Errorf("property \"%s\" can't take value \"%s\"", p.Name, v)
+			}
+		}
 
-	// top
-	if render[0] {
-		mesh = append(mesh,
-			uint32(x<<28|(y+1)<<20|z<<16|0x0<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureTop]+atlasWidth+1)<<4|BLOCK_TYPES[b].height),
-			uint32((x+1)<<28|(y+1)<<20|(z+1)<<16|0x0<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureTop])<<4|BLOCK_TYPES[b].height),
-			uint32((x+1)<<28|(y+1)<<20|z<<16|0x0<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureTop]+atlasWidth)<<4|BLOCK_TYPES[b].height),
+		bits := 0
+		for 1<<bits < len(p.Values) {
+			bits++
+		}
+		t.properties = append(t.properties, blockProperty{p.Name, p.Values, shift, bits})
+		shift += bits
+	}
+	if shift > 32-blockTypeBits {
+		return t, fmt.Errorf("the properties need %d bits of state, at most %d are available", shift, 32-blockTypeBits)
+	}
 
-			uint32(x<<28|(y+1)<<20|z<<16|0x0<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureTop]+atlasWidth+1)<<4|BLOCK_TYPES[b].height),
-			uint32(x<<28|(y+1)<<20|(z+1)<<16|0x0<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureTop]+1)<<4|BLOCK_TYPES[b].height),
-			uint32((x+1)<<28|(y+1)<<20|(z+1)<<16|0x0<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureTop])<<4|BLOCK_TYPES[b].height),
-		)
+	for _, textures := range append([]map[string]string{d.Textures}, variantTextures(d.Variants)...) {
+		for face := range textures {
+			if !slices.Contains(faceNames, face) {
+				return t, fmt.Errorf("unknown face \"%s\"", face)
+			}
+		}
 	}
 
-	// bottom
-	if render[1] {
-		mesh = append(mesh,
-			uint32(x<<28|y<<20|z<<16|0x1<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBottom]+1)<<4|15),
-			uint32((x+1)<<28|y<<20|z<<16|0x1<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBottom])<<4|15),
-			uint32((x+1)<<28|y<<20|(z+1)<<16|0x1<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBottom]+atlasWidth)<<4|15),
+	for state := range t.stateCount() {
+		values := make(map[string]string)
+		for _, p := range t.properties {
+			value, _ := (BlockId(state) << blockTypeBits).propertyOf(&p)
+			values[p.name] = value
+		}
+
+		model := cmp.Or(d.Model, defaultModel)
+		textures := maps.Clone(d.Textures)
+		height := 15
+		if d.Height != nil {
+			height = *d.Height
+		}
+		emission := d.Light
+		for _, v := range d.Variants {
+			matches := true
+			for property, value := range v.When {
+				matches = matches && values[property] == value
+			}
+			if !matches {
+				continue
+			}
+			model = cmp.Or(v.Model, model)
+			maps.Copy(textures, v.Textures)
+			if v.Height != nil {
+				height = *v.Height
+			}
+			if v.Light != nil {
+				emission = *v.Light
+			}
+		}
 
-			uint32((x+1)<<28|y<<20|(z+1)<<16|0x1<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBottom]+atlasWidth)<<4|15),
-			uint32(x<<28|y<<20|(z+1)<<16|0x1<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBottom]+1+atlasWidth)<<4|15),
-			uint32(x<<28|y<<20|z<<16|0x1<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBottom]+1)<<4|15),
-		)
+		if height < 0 || height > 15 {
+			return t, fmt.Errorf("height %d out of [0, 15]", height)
+		}
+		if !validLight(emission) {
+			return t, fmt.Errorf("light %v out of [0, %d]", emission, MAX_LIGHT)
+		}
+		faces := faceTextures(textures)
+		for _, f := range faces {
+			if f == "" {
+				return t, errors.New("a face has no texture")
+			}
+		}
+		rotation := noRotation
+		if facing, ok := values["facing"]; ok {
+			rotation = rotation.then(facingRotations[facing])
+		}
+		if axis, ok := values["axis"]; ok {
+			rotation = rotation.then(axisRotations[axis])
+		}
+
+		m := newBlockModel(shapes[model], faces, height+1, rotation)
+		if t.isLiquid {
+			filled := newBlockModel(shapes[model], faces, modelUnits, rotation)
+			m.filled = &filled
+		}
+		t.models = append(t.models, m)
+		t.lights = append(t.lights, colorLight(emission))
 	}
+	return t, nil
+}
 
-	// left
-	if render[2] {
-		mesh = append(mesh,
-			uint32(x<<28|y<<20|z<<16|0x2<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureLeft]+atlasWidth)<<4|15),
-			uint32(x<<28|y<<20|(z+1)<<16|0x2<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureLeft]+1+atlasWidth)<<4|15),
-			uint32(x<<28|(y+1)<<20|z<<16|0x2<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureLeft])<<4|BLOCK_TYPES[b].height),
+// validLight returns true if every level of rgb is between 0 and MAX_LIGHT
+func validLight(rgb [3]int) bool {
+	return !slices.ContainsFunc(rgb[:], func(level int) bool {
+		return level < 0 || level > MAX_LIGHT
+	})
+}
 
-			uint32(x<<28|y<<20|(z+1)<<16|0x2<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureLeft]+1+atlasWidth)<<4|15),
-			uint32(x<<28|(y+1)<<20|(z+1)<<16|0x2<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureLeft]+1)<<4|BLOCK_TYPES[b].height),
-			uint32(x<<28|(y+1)<<20|z<<16|0x2<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureLeft])<<4|BLOCK_TYPES[b].height),
-		)
+func variantTextures(variants []variantDefinition) []map[string]string {
+	textures := make([]map[string]string, len(variants))
+	for i, v := range variants {
+		textures[i] = v.Textures
 	}
+	return textures
+}
 
-	// right
-	if render[3] {
-		mesh = append(mesh,
-			uint32((x+1)<<28|y<<20|z<<16|0x3<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureRight]+1+atlasWidth)<<4|15),
-			uint32((x+1)<<28|(y+1)<<20|z<<16|0x3<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureRight]+1)<<4|BLOCK_TYPES[b].height),
-			uint32((x+1)<<28|y<<20|(z+1)<<16|0x3<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureRight]+atlasWidth)<<4|15),
+// textures returns the textures used by any state of the block
+func (t *blockType) textures() []string {
+	var textures []string
+	for _, m := range t.models {
+		for _, q := range m.quads {
+			textures = append(textures, q.texture)
+		}
+		for _, quads := range m.connections {
+			for _, q := range quads {
+				textures = append(textures, q.texture)
+			}
+		}
+	}
+	slices.Sort(textures)
+	return slices.Compact(textures)
+}
 
-			uint32((x+1)<<28|y<<20|(z+1)<<16|0x3<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureRight]+atlasWidth)<<4|15),
-			uint32((x+1)<<28|(y+1)<<20|z<<16|0x3<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureRight]+1)<<4|BLOCK_TYPES[b].height),
-			uint32((x+1)<<28|(y+1)<<20|(z+1)<<16|0x3<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureRight])<<4|BLOCK_TYPES[b].height),
-		)
+/*
+LoadBlocks loads the block registry at path and the models it uses from the models directory next to it,
+the ids are assigned in the order of the file starting from 1.
+*/
+func LoadBlocks(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile(): %w", err)
 	}
 
-	// front
-	if render[4] {
-		mesh = append(mesh,
-			uint32(x<<28|y<<20|(z+1)<<16|0x4<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureFront]+atlasWidth)<<4|15),
-			uint32((x+1)<<28|y<<20|(z+1)<<16|0x4<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureFront]+atlasWidth+1)<<4|15),
-			uint32((x+1)<<28|(y+1)<<20|(z+1)<<16|0x4<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureFront]+1)<<4|BLOCK_TYPES[b].height),
+	var definitions []blockDefinition
+	err = json.Unmarshal(data, &definitions)
+	if err != nil {
+		return fmt.Errorf("json.Unmarshal(): %w", err)
+	}
+	if len(definitions) > maxBlockTypes {
+		return fmt.Errorf("%d blocks defined, at most %d are supported", len(definitions), maxBlockTypes)
+	}
 
-			uint32(x<<28|y<<20|(z+1)<<16|0x4<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureFront]+atlasWidth)<<4|15),
-			uint32((x+1)<<28|(y+1)<<20|(z+1)<<16|0x4<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureFront]+1)<<4|BLOCK_TYPES[b].height),
-			uint32(x<<28|(y+1)<<20|(z+1)<<16|0x4<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureFront])<<4|BLOCK_TYPES[b].height),
-		)
+	var models []string
+	for _, d := range definitions {
+		models = append(models, d.models()...)
 	}
+	shapes, err := loadShapes(filepath.Join(filepath.Dir(path), "models"), models)
+	if err != nil {
+		return fmt.Errorf("loadShapes(): %w", err)
+	}
+
+	types := make(map[BlockId]blockType, len(definitions))
+	ids := map[string]BlockId{"air": AIR}
+	for i, d := range definitions {
+		if _, ok := ids[d.Name]; ok || d.Name == "" {
+			return fmt.Errorf("block %d: invalid or duplicate name \"%s\"", i, d.Name)
+		}
+		t, err := d.blockType(shapes)
+		if err != nil {
+			return fmt.Errorf("block \"%s\": %w", d.Name, err)
+		}
 
-	// back
-	if render[5] {
-		mesh = append(mesh,
-			uint32(x<<28|y<<20|z<<16|0x5<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBack]+1+atlasWidth)<<4|15),
-			uint32((x+1)<<28|(y+1)<<20|z<<16|0x5<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBack])<<4|BLOCK_TYPES[b].height),
-			uint32((x+1)<<28|y<<20|z<<16|0x5<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBack]+atlasWidth)<<4|15),
+		id := BlockId(i + 1)
+		types[id] = t
+		ids[d.Name] = id
+	}
 
-			uint32(x<<28|y<<20|z<<16|0x5<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBack]+1+atlasWidth)<<4|15),
-			uint32(x<<28|(y+1)<<20|z<<16|0x5<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBack]+1)<<4|BLOCK_TYPES[b].height),
-			uint32((x+1)<<28|(y+1)<<20|z<<16|0x5<<12|int(BLOCK_TEXTURE_ATLAS[BLOCK_TYPES[b].textureBack])<<4|BLOCK_TYPES[b].height),
-		)
+	for name, builtin := range builtinBlocks {
+		id, ok := ids[name]
+		if !ok {
+			return fmt.Errorf("block \"%s\" is used by the world generators but isn't defined", name)
+		}
+		*builtin = id
 	}
-	return mesh
+
+	BLOCK_TYPES = types
+	blockIds = ids
+	loadBiomes()
+	loadOres()
+	return nil
+}
+
+// BlockTextures returns the textures of the registry, each once, in the order of the blocks using them first, so adding blocks keeps the textures of the others in place
+func BlockTextures() []string {
+	return blockTextures(BLOCK_TYPES)
+}
+
+func blockTextures(types map[BlockId]blockType) []string {
+	var textures []string
+	for id := range len(types) {
+		t := types[BlockId(id+1)]
+		for _, texture := range t.textures() {
+			if !slices.Contains(textures, texture) {
+				textures = append(textures, texture)
+			}
+		}
+	}
+	return textures
+}
+
+func (b BlockId) Name() string {
+	if b == AIR {
+		return "air"
+	}
+	return BLOCK_TYPES[b.Type()].name
+}
+
+// TopTexture returns the file name of the texture of the top face of the block
+func (b BlockId) TopTexture() string {
+	return b.model().faces[faceTop]
+}
+
+// blockByName returns the block of name, which can hold the values of properties as in log[axis=x]
+func blockByName(name string) (BlockId, bool) {
+	return parseStateName(name)
 }
 
-// Visible returns true if block id back is visible through block id front
-func visible(front, back BlockId) bool {
-	return front == 0 ||
-		BLOCK_TYPES[front].isTransparent && front != back
+// faceVertices are the corners of every face of a block, in the order of QUAD_INDICES, 1 standing for the highest coordinate
+var faceVertices = [6][QUAD_VERTICES]utils.IntVector3{
+	faceTop:    {{X: 0, Y: 1, Z: 0}, {X: 0, Y: 1, Z: 1}, {X: 1, Y: 1, Z: 1}, {X: 1, Y: 1, Z: 0}},
+	faceBottom: {{X: 0, Y: 0, Z: 0}, {X: 1, Y: 0, Z: 0}, {X: 1, Y: 0, Z: 1}, {X: 0, Y: 0, Z: 1}},
+	faceLeft:   {{X: 0, Y: 1, Z: 0}, {X: 0, Y: 0, Z: 0}, {X: 0, Y: 0, Z: 1}, {X: 0, Y: 1, Z: 1}},
+	faceRight:  {{X: 1, Y: 1, Z: 0}, {X: 1, Y: 1, Z: 1}, {X: 1, Y: 0, Z: 1}, {X: 1, Y: 0, Z: 0}},
+	faceFront:  {{X: 0, Y: 0, Z: 1}, {X: 1, Y: 0, Z: 1}, {X: 1, Y: 1, Z: 1}, {X: 0, Y: 1, Z: 1}},
+	faceBack:   {{X: 0, Y: 0, Z: 0}, {X: 0, Y: 1, Z: 0}, {X: 1, Y: 1, Z: 0}, {X: 1, Y: 0, Z: 0}},
 }
@@ -0,0 +1,566 @@
+package level
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+// modelUnits is the size of a block in model coordinates
+const modelUnits = 16
+
+// faceNone is the face of the quads that don't point along an axis, as the ones of crosses
+const faceNone = 6
+
+var faceNormals = [6]utils.IntVector3{
+	faceTop:    {X: 0, Y: 1, Z: 0},
+	faceBottom: {X: 0, Y: -1, Z: 0},
+	faceLeft:   {X: -1, Y: 0, Z: 0},
+	faceRight:  {X: 1, Y: 0, Z: 0},
+	faceFront:  {X: 0, Y: 0, Z: 1},
+	faceBack:   {X: 0, Y: 0, Z: -1},
+}
+
+func opposite(face int) int {
+	return face ^ 1
+}
+
+/*
+modelDefinition is a file of the model directory, next to the block registry, as models/stairs.json:
+
+	{
+		"boxes": [{"from": [0, 0, 0], "to": [16, 8, 16]}, {"from": [0, 8, 0], "to": [16, 16, 8]}],
+		"crosses": [{"from": [0, 0, 0], "to": [16, 16, 16]}],  // optional
+		"connections": [{"from": [7, 12, 0], "to": [9, 15, 6]}] // optional
+	}
+
+Coordinates are in sixteenths of a block, models face south (+z).
+Every face of a box shows the texture of the face of the block it points to.
+A cross is made of 2 quads along the vertical diagonals of its box, showing the front texture.
+Connections are boxes reaching towards -z, added on every side where the block joins its neighbour:
+a block of the same type or one whose face is full and opaque.
+Boxes and connections are what entities collide with and rays hit, crosses can only be hit.
+*/
+type modelDefinition struct {
+	Boxes       []boxDefinition `json:"boxes"`
+	Crosses     []boxDefinition `json:"crosses"`
+	Connections []boxDefinition `json:"connections"`
+}
+
+type boxDefinition struct {
+	From [3]int `json:"from"`
+	To   [3]int `json:"to"`
+}
+
+type modelBox struct {
+	from utils.IntVector3
+	to   utils.IntVector3
+}
+
+func (d boxDefinition) box() (modelBox, error) {
+	b := modelBox{
+		utils.IntVector3{X: d.From[0], Y: d.From[1], Z: d.From[2]},
+		utils.IntVector3{X: d.To[0], Y: d.To[1], Z: d.To[2]},
+	}
+	for i := range 3 {
+		if d.From[i] < 0 || d.To[i] > modelUnits || d.From[i] >= d.To[i] {
+			return b, fmt.Errorf("box from %v to %v isn't inside the block", d.From, d.To)
+		}
+	}
+	return b, nil
+}
+
+func boxes(definitions []boxDefinition) ([]modelBox, error) {
+	boxes := make([]modelBox, len(definitions))
+	for i, d := range definitions {
+		b, err := d.box()
+		if err != nil {
+			return nil, err
+		}
+		boxes[i] = b
+	}
+	return boxes, nil
+}
+
+// blockShape is a model once its boxes are read
+type blockShape struct {
+	boxes       []modelBox
+	crosses     []modelBox
+	connections []modelBox
+}
+
+// loadShapes reads the model of every name from dir
+func loadShapes(dir string, names []string) (map[string]blockShape, error) {
+	shapes := make(map[string]blockShape)
+	for _, name := range names {
+		if _, ok := shapes[name]; ok {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile(): %w", err)
+		}
+		var d modelDefinition
+		err = json.Unmarshal(data, &d)
+		if err != nil {
+			return nil, fmt.Errorf("model \"%s\": json.Unmarshal(): %w", name, err)
+		}
+
+		var s blockShape
+		var errs [3]error
+		s.boxes, errs[0] = boxes(d.Boxes)
+		s.crosses, errs[1] = boxes(d.Crosses)
+		s.connections, errs[2] = boxes(d.Connections)
+		if err := errors.Join(errs[:]...); err != nil {
+			return nil, fmt.Errorf("model \"%s\": %w", name, err)
+		}
+		shapes[name] = s
+	}
+	return shapes, nil
+}
+
+// blockRotation turns a model around the center of the block, x, y and z are where the unit vectors end up
+type blockRotation struct {
+	x, y, z utils.IntVector3
+}
+
+var noRotation = blockRotation{faceNormals[faceRight], faceNormals[faceTop], faceNormals[faceFront]}
+
+var axisRotations = map[string]blockRotation{
+	"y": noRotation,
+	"x": {faceNormals[faceBottom], faceNormals[faceRight], faceNormals[faceFront]},
+	"z": {faceNormals[faceRight], faceNormals[faceFront], faceNormals[faceBottom]},
+}
+
+var facingRotations = map[string]blockRotation{
+	"south": noRotation,
+	"north": {faceNormals[faceLeft], faceNormals[faceTop], faceNormals[faceBack]},
+	"east":  {faceNormals[faceBack], faceNormals[faceTop], faceNormals[faceRight]},
+	"west":  {faceNormals[faceFront], faceNormals[faceTop], faceNormals[faceLeft]},
+}
+
+// sideRotations turn the connections, which reach towards -z, towards every side
+var sideRotations = map[int]blockRotation{
+	faceBack:  noRotation,
+	faceFront: facingRotations["north"],
+	faceRight: facingRotations["west"],
+	faceLeft:  facingRotations["east"],
+}
+
+func (r blockRotation) turn(v utils.IntVector3) utils.IntVector3 {
+	return utils.IntVector3{
+		X: r.x.X*v.X + r.y.X*v.Y + r.z.X*v.Z,
+		Y: r.x.Y*v.X + r.y.Y*v.Y + r.z.Y*v.Z,
+		Z: r.x.Z*v.X + r.y.Z*v.Y + r.z.Z*v.Z,
+	}
+}
+
+// then returns the rotation turning by r and then by next
+func (r blockRotation) then(next blockRotation) blockRotation {
+	return blockRotation{next.turn(r.x), next.turn(r.y), next.turn(r.z)}
+}
+
+// point turns a point in model coordinates
+func (r blockRotation) point(p utils.IntVector3) utils.IntVector3 {
+	center := utils.IntVector3{X: modelUnits / 2, Y: modelUnits / 2, Z: modelUnits / 2}
+	return r.turn(p.Sub(center)).Add(center)
+}
+
+func (r blockRotation) face(face int) int {
+	if face == faceNone {
+		return faceNone
+	}
+	normal := r.turn(faceNormals[face])
+	for f, n := range faceNormals {
+		if n == normal {
+			return f
+		}
+	}
+	return faceNone
+}
+
+func (r blockRotation) box(b modelBox) modelBox {
+	from, to := r.point(b.from), r.point(b.to)
+	return modelBox{
+		utils.IntVector3{X: min(from.X, to.X), Y: min(from.Y, to.Y), Z: min(from.Z, to.Z)},
+		utils.IntVector3{X: max(from.X, to.X), Y: max(from.Y, to.Y), Z: max(from.Z, to.Z)},
+	}
+}
+
+// faceCoverage is the part of a face of a block hidden by a model, one bit per sixteenth of block
+type faceCoverage [modelUnits]uint16
+
+var fullCoverage = faceCoverage{
+	0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF,
+	0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF,
+}
+
+// faceRectangle returns the coverage of the rectangle from from to to projected on face
+func faceRectangle(face int, from, to utils.IntVector3) faceCoverage {
+	// columns along x for the horizontal and the front and back faces, z otherwise, rows along z for the horizontal faces, y otherwise
+	a0, a1, b0, b1 := from.X, to.X, from.Y, to.Y
+	switch face {
+	case faceTop, faceBottom:
+		b0, b1 = from.Z, to.Z
+	case faceLeft, faceRight:
+		a0, a1 = from.Z, to.Z
+	}
+
+	var c faceCoverage
+	row := uint16((1<<a1 - 1) &^ (1<<a0 - 1))
+	for b := b0; b < b1; b++ {
+		c[b] = row
+	}
+	return c
+}
+
+func (c *faceCoverage) add(o faceCoverage) {
+	for i := range c {
+		c[i] |= o[i]
+	}
+}
+
+// contains returns true if every part of o is in c
+func (c faceCoverage) contains(o faceCoverage) bool {
+	for i := range c {
+		if o[i]&^c[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// onFace returns the face of the block the side face of b lies on, -1 if it is inside the block
+func (b modelBox) onFace(face int) int {
+	switch {
+	case face == faceTop && b.to.Y == modelUnits,
+		face == faceBottom && b.from.Y == 0,
+		face == faceLeft && b.from.X == 0,
+		face == faceRight && b.to.X == modelUnits,
+		face == faceFront && b.to.Z == modelUnits,
+		face == faceBack && b.from.Z == 0:
+		return face
+	}
+	return -1
+}
+
+type modelVertex struct {
+	position utils.IntVector3 // in sixteenths of block from the lowest corner of the block
+	u, v     int              // in sixteenths of texture
+}
+
+// modelQuad is a face of a model, its corners in the order of QUAD_INDICES
+type modelQuad struct {
+	vertices [QUAD_VERTICES]modelVertex
+	texture  string
+	face     int          // face the quad is shaded as
+	cullface int          // face of the block the quad lies on, -1 if it is inside the block
+	area     faceCoverage // part of cullface the quad covers
+}
+
+/*
+boxQuad returns the face of box b, in model coordinates.
+The texture coordinates follow the position of the vertices in the block, so the faces of smaller boxes show the matching part of the texture.
+*/
+func boxQuad(b modelBox, face int, texture string) modelQuad {
+	q := modelQuad{texture: texture, face: face, cullface: b.onFace(face)}
+	for i, corner := range faceVertices[face] {
+		p := b.from
+		if corner.X == 1 {
+			p.X = b.to.X
+		}
+		if corner.Y == 1 {
+			p.Y = b.to.Y
+		}
+		if corner.Z == 1 {
+			p.Z = b.to.Z
+		}
+
+		var u, v int
+		switch face {
+		case faceTop:
+			u, v = modelUnits-p.X, modelUnits-p.Z
+		case faceBottom:
+			u, v = modelUnits-p.X, p.Z
+		case faceLeft:
+			u, v = p.Z, modelUnits-p.Y
+		case faceRight:
+			u, v = modelUnits-p.Z, modelUnits-p.Y
+		case faceFront:
+			u, v = p.X, modelUnits-p.Y
+		case faceBack:
+			u, v = modelUnits-p.X, modelUnits-p.Y
+		}
+		q.vertices[i] = modelVertex{p, u, v}
+	}
+	return q
+}
+
+// crossQuads returns the 2 quads along the vertical diagonals of b, the renderer doesn't cull back faces so each is seen from both sides
+func crossQuads(b modelBox, texture string) [2]modelQuad {
+	diagonals := [2][2]utils.IntVector2{
+		{{X: b.from.X, Y: b.from.Z}, {X: b.to.X, Y: b.to.Z}},
+		{{X: b.from.X, Y: b.to.Z}, {X: b.to.X, Y: b.from.Z}},
+	}
+
+	var quads [2]modelQuad
+	for i, d := range diagonals {
+		bottom0 := modelVertex{utils.IntVector3{X: d[0].X, Y: b.from.Y, Z: d[0].Y}, 0, modelUnits - b.from.Y}
+		bottom1 := modelVertex{utils.IntVector3{X: d[1].X, Y: b.from.Y, Z: d[1].Y}, modelUnits, modelUnits - b.from.Y}
+		top0 := modelVertex{utils.IntVector3{X: d[0].X, Y: b.to.Y, Z: d[0].Y}, 0, modelUnits - b.to.Y}
+		top1 := modelVertex{utils.IntVector3{X: d[1].X, Y: b.to.Y, Z: d[1].Y}, modelUnits, modelUnits - b.to.Y}
+		quads[i] = modelQuad{
+			vertices: [QUAD_VERTICES]modelVertex{bottom0, bottom1, top1, top0},
+			texture:  texture,
+			face:     faceNone,
+			cullface: -1,
+		}
+	}
+	return quads
+}
+
+func (q modelQuad) rotate(r blockRotation) modelQuad {
+	for i := range q.vertices {
+		q.vertices[i].position = r.point(q.vertices[i].position)
+	}
+	q.face = r.face(q.face)
+	if q.cullface >= 0 {
+		q.cullface = r.face(q.cullface)
+
+		from, to := q.vertices[0].position, q.vertices[0].position
+		for _, v := range q.vertices {
+			from = utils.IntVector3{X: min(from.X, v.position.X), Y: min(from.Y, v.position.Y), Z: min(from.Z, v.position.Z)}
+			to = utils.IntVector3{X: max(to.X, v.position.X), Y: max(to.Y, v.position.Y), Z: max(to.Z, v.position.Z)}
+		}
+		q.area = faceRectangle(q.cullface, from, to)
+	}
+	return q
+}
+
+// blockModel is the look and the shape of a block in a given state
+type blockModel struct {
+	faces           [6]string // texture of every face of the block, as seen on a full block
+	quads           []modelQuad
+	connections     [6][]modelQuad // quads of the connection towards every side
+	boxes           []modelBox     // solid boxes, without the connections
+	connectionBoxes [6][]modelBox
+	crosses         []modelBox
+	coverage        [6]faceCoverage // part of the faces of the block the boxes hide
+	filled          *blockModel     // for liquids, the model filling the whole block, used under the same liquid
+}
+
+// squash lowers the top of b so a full block is height sixteenths high
+func squash(b modelBox, height int) modelBox {
+	b.from.Y = b.from.Y * height / modelUnits
+	b.to.Y = max(b.to.Y*height/modelUnits, b.from.Y+1)
+	return b
+}
+
+func shapeBoxes(boxes []modelBox, height int, r blockRotation) []modelBox {
+	out := make([]modelBox, len(boxes))
+	for i, b := range boxes {
+		out[i] = r.box(squash(b, height))
+	}
+	return out
+}
+
+/*
+newBlockModel returns the model of shape turned by r, squashed to height sixteenths,
+textures are the textures of the faces of the block before the rotation.
+*/
+func newBlockModel(shape blockShape, textures [6]string, height int, r blockRotation) blockModel {
+	var m blockModel
+	for face, texture := range textures {
+		m.faces[r.face(face)] = texture
+	}
+
+	for _, b := range shape.boxes {
+		for face := range faceVertices {
+			m.quads = append(m.quads, boxQuad(squash(b, height), face, textures[face]).rotate(r))
+		}
+	}
+	for _, b := range shape.crosses {
+		for _, q := range crossQuads(squash(b, height), textures[faceFront]) {
+			m.quads = append(m.quads, q.rotate(r))
+		}
+	}
+	m.boxes = shapeBoxes(shape.boxes, height, r)
+	m.crosses = shapeBoxes(shape.crosses, height, r)
+
+	for side, sideRotation := range sideRotations {
+		for _, b := range shape.connections {
+			for face := range faceVertices {
+				m.connections[side] = append(m.connections[side], boxQuad(squash(b, height), face, textures[face]).rotate(sideRotation))
+			}
+		}
+		m.connectionBoxes[side] = shapeBoxes(shape.connections, height, sideRotation)
+	}
+
+	for _, b := range m.boxes {
+		for face := range faceNormals {
+			if b.onFace(face) >= 0 {
+				m.coverage[face].add(faceRectangle(face, b.from, b.to))
+			}
+		}
+	}
+	return m
+}
+
+// blockSurroundings is what the neighbours of a block change to its look and its shape
+type blockSurroundings struct {
+	hidden    [6]faceCoverage // part of every face of the block hidden by its neighbour
+	connected [6]bool         // the block joins its neighbour, only for the 4 sides
+	filled    bool            // a liquid under the same liquid fills its whole block
+}
+
+/*
+surroundings returns the surroundings of b among neighbours, indexed by face.
+A face is hidden by an opaque neighbour or one of the same type, where their models touch.
+Missing neighbours, in chunks that aren't loaded, hide the whole face.
+*/
+func (b BlockId) surroundings(neighbours [6]BlockId, loaded [6]bool) blockSurroundings {
+	var s blockSurroundings
+	liquid := BLOCK_TYPES[b.Type()].isLiquid
+	s.filled = liquid && loaded[faceTop] && neighbours[faceTop].Type() == b.Type()
+
+	for face, n := range neighbours {
+		if !loaded[face] {
+			s.hidden[face] = fullCoverage
+			continue
+		}
+		if n == AIR {
+			continue
+		}
+
+		sameType := n.Type() == b.Type()
+		coverage := n.model().coverage[opposite(face)]
+		switch {
+		case sameType && liquid:
+			s.hidden[face] = fullCoverage
+		case sameType || !BLOCK_TYPES[n.Type()].isTransparent:
+			s.hidden[face] = coverage
+		}
+
+		if face != faceTop && face != faceBottom {
+			s.connected[face] = sameType || !BLOCK_TYPES[n.Type()].isTransparent && coverage == fullCoverage
+		}
+	}
+	return s
+}
+
+func (b BlockId) shapedModel(s blockSurroundings) blockModel {
+	model := b.model()
+	if s.filled && model.filled != nil {
+		return *model.filled
+	}
+	return model
+}
+
+/*
+A vertex of a ChunkMesh is 3 words:
+
+	x (8bits) | y (12bits) | z (8bits) | face (4bits), positions in sixteenths of block from the origin of the section
+	texture layer (14bits) | u (9bits) | v (9bits), u and v in sixteenths of texture
+	ambient occlusion (2bits) | light of the vertex (16bits), as held by light
+
+The texture repeats past 16 sixteenths, so the quads merged by greedyMesher tile it.
+*/
+func appendVertex(mesh []uint32, p utils.IntVector3, face, texture, u, v int, l light, occlusion int) []uint32 {
+	return append(mesh,
+		uint32(p.X<<24|p.Y<<12|p.Z<<4|face),
+		uint32(texture<<18|u<<9|v),
+		uint32(occlusion<<16)|uint32(l),
+	)
+}
+
+// light returns the light the quad is drawn with when it isn't smoothly lit, given the light of its block and of the blocks around: the one of the block it faces
+func (q modelQuad) light(lights *blockLights) light {
+	if q.cullface < 0 {
+		return lights.at(utils.IntVector3{})
+	}
+	return lights.at(faceNormals[q.cullface])
+}
+
+// visibleQuads returns the quads of the block with surroundings s that its neighbours don't hide
+func (b BlockId) visibleQuads(s blockSurroundings) iter.Seq[modelQuad] {
+	return func(yield func(modelQuad) bool) {
+		if b == AIR {
+			return
+		}
+
+		model := b.shapedModel(s)
+		visible := func(q modelQuad) bool {
+			return q.cullface < 0 || !s.hidden[q.cullface].contains(q.area)
+		}
+		for _, q := range model.quads {
+			if visible(q) && !yield(q) {
+				return
+			}
+		}
+		for face, connected := range s.connected {
+			if !connected {
+				continue
+			}
+			for _, q := range model.connections[face] {
+				if visible(q) && !yield(q) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// appendQuad appends the vertices of q for a block at x, y, z in its section, drawn with the light and the ambient occlusion of its vertices
+func appendQuad(mesh []uint32, q modelQuad, x, y, z int, l quadLight, occlusion quadOcclusion) []uint32 {
+	texture := BLOCK_TEXTURE_LAYERS[q.texture]
+	origin := utils.IntVector3{X: x * modelUnits, Y: y * modelUnits, Z: z * modelUnits}
+	first := occlusion.firstVertex()
+	for i := range QUAD_VERTICES {
+		j := (first + i) % QUAD_VERTICES
+		vertex := q.vertices[j]
+		mesh = appendVertex(mesh, origin.Add(vertex.position), q.face, texture, vertex.u, vertex.v, l[j], occlusion[j])
+	}
+	return mesh
+}
+
+// mesh returns the vertices of the quads of the block at x, y, z in its section that its neighbours don't hide, one quad per face, unlit and without occlusion
+func (b BlockId) mesh(x, y, z int, s blockSurroundings) []uint32 {
+	mesh := []uint32{}
+	for q := range b.visibleQuads(s) {
+		mesh = appendQuad(mesh, q, x, y, z, quadLight{}, quadOcclusion{})
+	}
+	return mesh
+}
+
+/*
+boxes returns the boxes of the block with surroundings s, in blocks from its lowest corner.
+Only the ones entities collide with are returned if solid is true, liquids have none.
+*/
+func (b BlockId) boxes(s blockSurroundings, solid bool) []Box {
+	if b == AIR || solid && BLOCK_TYPES[b.Type()].isLiquid {
+		return nil
+	}
+
+	model := b.shapedModel(s)
+	shape := append([]modelBox{}, model.boxes...)
+	for face, connected := range s.connected {
+		if connected {
+			shape = append(shape, model.connectionBoxes[face]...)
+		}
+	}
+	if !solid {
+		shape = append(shape, model.crosses...)
+	}
+
+	boxes := make([]Box, len(shape))
+	for i, m := range shape {
+		boxes[i] = Box{modelToBlock(m.from), modelToBlock(m.to)}
+	}
+	return boxes
+}
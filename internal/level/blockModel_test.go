@@ -0,0 +1,91 @@
+package level
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+func mustBlock(t *testing.T, name string) BlockId {
+	t.Helper()
+	b, ok := blockByName(name)
+	if !ok {
+		t.Fatalf("%s isn't in the registry", name)
+	}
+	return b
+}
+
+func loadedNeighbours() [6]bool {
+	return [6]bool{true, true, true, true, true, true}
+}
+
+func TestSlabCulling(t *testing.T) {
+	slab := mustBlock(t, "stone_slab")
+	quads := Quads(slab.mesh(0, 0, 0, blockSurroundings{}))
+	if quads != 6 {
+		t.Fatalf("a lone slab has %d quads, want 6", quads)
+	}
+
+	// stone under the slab hides its bottom, stone above hides nothing since the top of the slab is inside the block
+	var neighbours [6]BlockId
+	neighbours[faceBottom], neighbours[faceTop] = STONE, STONE
+	s := slab.surroundings(neighbours, loadedNeighbours())
+	if quads := Quads(slab.mesh(0, 0, 0, s)); quads != 5 {
+		t.Errorf("a slab between stones has %d quads, want 5", quads)
+	}
+
+	// the stone above a slab shows its bottom, the one next to it only the part above the slab
+	neighbours = [6]BlockId{}
+	neighbours[faceBottom] = slab
+	if s := STONE.surroundings(neighbours, loadedNeighbours()); s.hidden[faceBottom].contains(fullCoverage) {
+		t.Error("a slab hides the bottom of the stone above it")
+	}
+	neighbours = [6]BlockId{}
+	neighbours[faceLeft] = slab
+	hidden := STONE.surroundings(neighbours, loadedNeighbours()).hidden[faceLeft]
+	if !hidden.contains(faceRectangle(faceLeft, utils.IntVector3{}, utils.IntVector3{X: 0, Y: 8, Z: 16})) || hidden.contains(fullCoverage) {
+		t.Errorf("a slab hides %v of the side of the stone next to it, want its lower half", hidden)
+	}
+
+	top := slab.WithPlacement(faceNormals[faceBottom], mgl32.Vec3{0, 0, -1})
+	if top.StateName() != "stone_slab[half=top]" {
+		t.Errorf("a slab placed under a block is %s", top.StateName())
+	}
+}
+
+func TestModelShapes(t *testing.T) {
+	stairs := mustBlock(t, "stone_stairs").WithProperty("facing", "east")
+	boxes := stairs.boxes(blockSurroundings{}, true)
+	want := []Box{{mgl32.Vec3{0, 0, 0}, mgl32.Vec3{1, 0.5, 1}}, {mgl32.Vec3{0, 0.5, 0}, mgl32.Vec3{0.5, 1, 1}}}
+	if len(boxes) != len(want) || boxes[0] != want[0] || boxes[1] != want[1] {
+		t.Errorf("boxes of stairs facing east = %v, want %v", boxes, want)
+	}
+
+	// fences join the fences and the full blocks around them
+	fence := mustBlock(t, "fence")
+	var neighbours [6]BlockId
+	neighbours[faceBack], neighbours[faceRight], neighbours[faceLeft] = fence, STONE, TALL_GRASS
+	s := fence.surroundings(neighbours, loadedNeighbours())
+	if !s.connected[faceBack] || !s.connected[faceRight] || s.connected[faceLeft] || s.connected[faceFront] {
+		t.Errorf("fence connections = %v", s.connected)
+	}
+	if n := len(fence.boxes(s, true)); n != 5 {
+		t.Errorf("a fence joining 2 neighbours has %d boxes, want 5", n)
+	}
+
+	// plants can be hit but not collided with
+	if n := len(TALL_GRASS.boxes(blockSurroundings{}, true)); n != 0 {
+		t.Errorf("tall grass has %d solid boxes", n)
+	}
+	hit := TALL_GRASS.boxes(blockSurroundings{}, false)
+	if len(hit) != 1 {
+		t.Fatalf("tall grass has %d boxes to hit, want 1", len(hit))
+	}
+	if _, ok := hit[0].IntersectRay(mgl32.Vec3{0.5, 2, 0.5}, mgl32.Vec3{0, -1, 0}); !ok {
+		t.Error("a ray going down through tall grass misses it")
+	}
+	if _, ok := hit[0].IntersectRay(mgl32.Vec3{0.05, 2, 0.05}, mgl32.Vec3{0, -1, 0}); ok {
+		t.Error("a ray going down next to tall grass hits it")
+	}
+}
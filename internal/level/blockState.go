@@ -0,0 +1,163 @@
+package level
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+/*
+A BlockId holds the block type in its low blockTypeBits bits and the state of the block above them.
+The state packs the index of the value of every property of the type, in the order they are declared.
+The default state, where every property has its first value, is 0 so a type id is also its default block.
+*/
+const blockTypeBits = 16
+
+// maxBlockTypes is the number of types the registry can hold, besides AIR
+const maxBlockTypes = 1<<blockTypeBits - 1
+
+const (
+	faceTop = iota
+	faceBottom
+	faceLeft  // -x
+	faceRight // +x
+	faceFront // +z
+	faceBack  // -z
+)
+
+type blockProperty struct {
+	name   string
+	values []string
+	shift  int // position of the property's bits in the state
+	bits   int
+}
+
+func (b BlockId) Type() BlockId {
+	return b & (1<<blockTypeBits - 1)
+}
+
+func (b BlockId) state() int {
+	return int(b >> blockTypeBits)
+}
+
+func (b BlockId) model() blockModel {
+	models := BLOCK_TYPES[b.Type()].models
+	if b.state() >= len(models) {
+		return blockModel{}
+	}
+	return models[b.state()]
+}
+
+func (b BlockId) property(name string) (*blockProperty, bool) {
+	t := BLOCK_TYPES[b.Type()]
+	for i := range t.properties {
+		if t.properties[i].name == name {
+			return &t.properties[i], true
+		}
+	}
+	return nil, false
+}
+
+// Property returns the value of the property of the block, ok is false if its type doesn't have it
+func (b BlockId) Property(name string) (value string, ok bool) {
+	p, ok := b.property(name)
+	if !ok {
+		return "", false
+	}
+	return b.propertyOf(p)
+}
+
+// propertyOf returns the value of p in the state of the block, out of range values are read as the default one
+func (b BlockId) propertyOf(p *blockProperty) (string, bool) {
+	i := b.state() >> p.shift & (1<<p.bits - 1)
+	if i >= len(p.values) {
+		return p.values[0], true
+	}
+	return p.values[i], true
+}
+
+// WithProperty returns the block with the property set to value, it is unchanged if its type doesn't have the property or the value
+func (b BlockId) WithProperty(name, value string) BlockId {
+	p, ok := b.property(name)
+	if !ok {
+		return b
+	}
+	for i, v := range p.values {
+		if v == value {
+			state := b.state()&^((1<<p.bits-1)<<p.shift) | i<<p.shift
+			return b.Type() | BlockId(state)<<blockTypeBits
+		}
+	}
+	return b
+}
+
+/*
+WithPlacement returns the block placed against the face of normal face by a player looking towards look:
+its axis follows the normal of the face, it sits in the top half when placed under a block and its front faces the player.
+*/
+func (b BlockId) WithPlacement(face utils.IntVector3, look mgl32.Vec3) BlockId {
+	if face.Y < 0 {
+		b = b.WithProperty("half", "top")
+	}
+	switch {
+	case face.X != 0:
+		b = b.WithProperty("axis", "x")
+	case face.Y != 0:
+		b = b.WithProperty("axis", "y")
+	case face.Z != 0:
+		b = b.WithProperty("axis", "z")
+	}
+
+	if math.Abs(float64(look.X())) > math.Abs(float64(look.Z())) {
+		if look.X() > 0 {
+			return b.WithProperty("facing", "west")
+		}
+		return b.WithProperty("facing", "east")
+	}
+	if look.Z() > 0 {
+		return b.WithProperty("facing", "north")
+	}
+	return b.WithProperty("facing", "south")
+}
+
+// StateName returns the name of the block followed by the values of its properties, as in log[axis=x]
+func (b BlockId) StateName() string {
+	t := BLOCK_TYPES[b.Type()]
+	if len(t.properties) == 0 {
+		return b.Name()
+	}
+
+	values := make([]string, len(t.properties))
+	for i, p := range t.properties {
+		value, _ := b.Property(p.name)
+		values[i] = p.name + "=" + value
+	}
+	return fmt.Sprintf("%s[%s]", b.Name(), strings.Join(values, ","))
+}
+
+// parseStateName returns the block named by name, as returned by StateName, unknown properties are ignored
+func parseStateName(name string) (BlockId, bool) {
+	typeName, properties, hasProperties := strings.Cut(name, "[")
+	b, ok := blockIds[typeName]
+	if !ok || !hasProperties {
+		return b, ok
+	}
+
+	for _, property := range strings.Split(strings.TrimSuffix(properties, "]"), ",") {
+		key, value, _ := strings.Cut(property, "=")
+		b = b.WithProperty(key, value)
+	}
+	return b, true
+}
+
+// stateCount returns the number of states of the type, including the ones holding values out of range of a property
+func (t *blockType) stateCount() int {
+	bits := 0
+	for _, p := range t.properties {
+		bits += p.bits
+	}
+	return 1 << bits
+}
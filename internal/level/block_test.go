@@ -0,0 +1,47 @@
+package level
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestBlockStateName(t *testing.T) {
+	furnace, ok := blockByName("furnace")
+	if !ok {
+		t.Fatal("furnace isn't in the registry")
+	}
+
+	b := furnace.WithProperty("facing", "east").WithProperty("lit", "true")
+	if b.Type() != furnace {
+		t.Errorf("Type() = %d, want %d", b.Type(), furnace)
+	}
+	if name := b.StateName(); name != "furnace[facing=east,lit=true]" {
+		t.Errorf("StateName() = %s", name)
+	}
+	if parsed, ok := blockByName(b.StateName()); !ok || parsed != b {
+		t.Errorf("blockByName(%s) = %d, want %d", b.StateName(), parsed, b)
+	}
+
+	if face := b.model().faces[faceRight]; face != "furnace_front_lit.png" {
+		t.Errorf("east face of a lit furnace facing east is %s, want furnace_front_lit.png", face)
+	}
+}
+
+func TestBlockTextureLayersStayInPlace(t *testing.T) {
+	textures := BlockTextures()
+	if len(textures) != len(slices.Compact(slices.Sorted(slices.Values(textures)))) {
+		t.Errorf("BlockTextures() = %v, a texture is listed twice", textures)
+	}
+
+	// a block added to the registry appends its new textures, the others keep their layers
+	types := maps.Clone(BLOCK_TYPES)
+	types[BlockId(len(types)+1)] = blockType{
+		name:   "added",
+		models: []blockModel{{quads: []modelQuad{{texture: "added.png"}, {texture: textures[0]}}}},
+	}
+	added := blockTextures(types)
+	if !slices.Equal(added, append(slices.Clone(textures), "added.png")) {
+		t.Errorf("the textures after adding a block are %v, want %v followed by added.png", added, textures)
+	}
+}
@@ -0,0 +1,65 @@
+package level
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+// Box is an axis aligned box, in level coordinates unless stated otherwise
+type Box struct {
+	Min mgl32.Vec3
+	Max mgl32.Vec3
+}
+
+func modelToBlock(p utils.IntVector3) mgl32.Vec3 {
+	return mgl32.Vec3{float32(p.X) / modelUnits, float32(p.Y) / modelUnits, float32(p.Z) / modelUnits}
+}
+
+func (b Box) Offset(offset mgl32.Vec3) Box {
+	return Box{b.Min.Add(offset), b.Max.Add(offset)}
+}
+
+// Intersects returns true if the insides of the boxes overlap, boxes only touching don't
+func (b Box) Intersects(o Box) bool {
+	for i := range 3 {
+		if b.Max[i] <= o.Min[i] || o.Max[i] <= b.Min[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// expand returns the box covering b along the whole displacement
+func (b Box) expand(displacement mgl32.Vec3) Box {
+	for i, d := range displacement {
+		if d < 0 {
+			b.Min[i] += d
+		} else {
+			b.Max[i] += d
+		}
+	}
+	return b
+}
+
+// IntersectRay returns the distance along direction at which the ray from origin enters the box, in lengths of direction
+func (b Box) IntersectRay(origin, direction mgl32.Vec3) (float32, bool) {
+	near, far := float32(math.Inf(-1)), float32(math.Inf(1))
+	for i := range 3 {
+		if direction[i] == 0 {
+			if origin[i] < b.Min[i] || origin[i] > b.Max[i] {
+				return 0, false
+			}
+			continue
+		}
+
+		t0 := (b.Min[i] - origin[i]) / direction[i]
+		t1 := (b.Max[i] - origin[i]) / direction[i]
+		near, far = max(near, min(t0, t1)), min(far, max(t0, t1))
+	}
+	if near > far || far < 0 {
+		return 0, false
+	}
+	return max(near, 0), true
+}
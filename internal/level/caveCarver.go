@@ -0,0 +1,87 @@
+package level
+
+import "github.com/vparent05/minecraft_go/internal/utils"
+
+const (
+	CHEESE_CAVE_THRESHOLD = 0.35 // cheese noise above which caverns are carved
+	CHEESE_CAVE_MIN_DEPTH = 8    // caverns stay this many blocks below the heightmap
+	SPAGHETTI_CAVE_WIDTH  = 0.05 // distance to the zero of both spaghetti noises under which tunnels are carved
+)
+
+/*
+caveCarver digs two kinds of caves out of the terrain:
+cheese caves are large caverns where a 3D noise is high,
+spaghetti caves are long tunnels following the intersection of the zero surfaces of two 3D noises.
+Each column scales both by the caveDensity of its biomes.
+*/
+type caveCarver struct {
+	cheeseNoise     *utils.Noise
+	spaghettiNoiseA *utils.Noise
+	spaghettiNoiseB *utils.Noise
+}
+
+func newCaveCarver(seed int64) *caveCarver {
+	return &caveCarver{
+		cheeseNoise:     utils.NewNoise(utils.DeriveSeed(seed, "cheese_caves")),
+		spaghettiNoiseA: utils.NewNoise(utils.DeriveSeed(seed, "spaghetti_caves_a")),
+		spaghettiNoiseB: utils.NewNoise(utils.DeriveSeed(seed, "spaghetti_caves_b")),
+	}
+}
+
+func (c *caveCarver) carve(coordinates utils.IntVector2, columns *[CHUNK_WIDTH][CHUNK_WIDTH]column, blocks *chunkBlocks) {
+	cheese := newNoiseGrid(coordinates, func(x, y, z float32) float32 {
+		// squashed vertically for wide and flat caverns
+		return c.cheeseNoise.ScaledFractalNoise3(x, y*2, z, 0.012, 2)
+	})
+	spaghettiA := newNoiseGrid(coordinates, func(x, y, z float32) float32 {
+		return c.spaghettiNoiseA.ScaledFractalNoise3(x, y, z, 0.02, 1)
+	})
+	spaghettiB := newNoiseGrid(coordinates, func(x, y, z float32) float32 {
+		return c.spaghettiNoiseB.ScaledFractalNoise3(x, y, z, 0.02, 1)
+	})
+
+	for i := range CHUNK_WIDTH {
+		for j := range CHUNK_WIDTH {
+			col := columns[i][j]
+			if col.caveDensity <= 0 {
+				continue
+			}
+			x := i + coordinates.X*CHUNK_WIDTH
+			z := j + coordinates.Y*CHUNK_WIDTH
+			width := SPAGHETTI_CAVE_WIDTH * col.caveDensity
+
+			top := min(int(col.height+col.overhang), MAX_Y-1)
+			for y := MIN_Y + 1; y <= top; y++ {
+				if b := blocks[i][y-MIN_Y][j]; b == AIR || b == WATER || b == ICE {
+					continue
+				}
+
+				carved := y < int(col.height)-CHEESE_CAVE_MIN_DEPTH && cheese.sample(x, y, z)*col.caveDensity > CHEESE_CAVE_THRESHOLD
+				if !carved {
+					a, b := spaghettiA.sample(x, y, z), spaghettiB.sample(x, y, z)
+					carved = a*a+b*b < width*width
+				}
+
+				if carved && !nextToWater(blocks, i, y, j) {
+					blocks[i][y-MIN_Y][j] = AIR
+				}
+			}
+		}
+	}
+}
+
+// nextToWater returns true if the block above or a horizontal neighbour in the chunk is water,
+// carving it would leave a wall of water standing
+func nextToWater(blocks *chunkBlocks, i, y, j int) bool {
+	if y > WATER_LEVEL {
+		return false
+	}
+	k := y - MIN_Y
+	isWater := func(b BlockId) bool { return b == WATER || b == ICE }
+
+	return isWater(blocks[i][k+1][j]) ||
+		i > 0 && isWater(blocks[i-1][k][j]) ||
+		i+1 < CHUNK_WIDTH && isWater(blocks[i+1][k][j]) ||
+		j > 0 && isWater(blocks[i][k][j-1]) ||
+		j+1 < CHUNK_WIDTH && isWater(blocks[i][k][j+1])
+}
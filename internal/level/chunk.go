@@ -1,59 +1,146 @@
 package level
 
 import (
+	"fmt"
 	"iter"
-	"math"
 	"sync"
-	"time"
+	"sync/atomic"
 
-	"github.com/go-gl/mathgl/mgl32"
 	"github.com/vparent05/minecraft_go/internal/utils"
 	"github.com/vparent05/minecraft_go/internal/utils/atomicx"
-	"github.com/vparent05/minecraft_go/internal/utils/chanx"
 )
 
 const CHUNK_WIDTH = 15
-const CHUNK_HEIGHT = 255
 
+// The blocks of a chunk go from y = MIN_Y included to y = MAX_Y excluded
+const (
+	MIN_Y        = -64
+	MAX_Y        = 320
+	CHUNK_HEIGHT = MAX_Y - MIN_Y
+)
+
+// chunkBlocks holds the blocks of a chunk, the block at y is at index y - MIN_Y
+type chunkBlocks = [CHUNK_WIDTH][CHUNK_HEIGHT][CHUNK_WIDTH]BlockId
+
+// VERTEX_SIZE is the number of words of a vertex of a ChunkMesh
+const VERTEX_SIZE = 3
+
+// QUAD_VERTICES is the number of vertices of a quad of a ChunkMesh, drawn as the triangles of QUAD_INDICES
+const QUAD_VERTICES = 4
+
+var QUAD_INDICES = [6]uint32{0, 1, 2, 0, 2, 3}
+
+/*
+ChunkMesh is the mesh of a section of a chunk, its vertices are relative to the bottom of the section.
+Solid and Transparent hold quads of QUAD_VERTICES vertices, drawn with the indices returned by QuadIndices.
+*/
 type ChunkMesh struct {
 	Solid       []uint32
 	Transparent []uint32
+	Visibility  SectionVisibility
+}
+
+// Quads returns the number of quads of the vertices of a ChunkMesh
+func Quads(vertices []uint32) int {
+	return len(vertices) / (QUAD_VERTICES * VERTEX_SIZE)
+}
+
+// QuadIndices returns the indices drawing the first quads quads of any ChunkMesh
+func QuadIndices(quads int) []uint32 {
+	indices := make([]uint32, 0, quads*len(QUAD_INDICES))
+	for i := range quads {
+		for _, index := range QUAD_INDICES {
+			indices = append(indices, uint32(i*QUAD_VERTICES)+index)
+		}
+	}
+	return indices
+}
+
+// SectionSet is a set of sections of a chunk, section i is in it if bit i is set
+type SectionSet uint32
+
+const allSections SectionSet = 1<<SECTION_COUNT - 1
+
+func (s SectionSet) Has(i int) bool {
+	return s&(1<<i) != 0
+}
+
+// sectionsAround returns the sections whose mesh shows the block at y, the one next to its own is included when y is on their border
+func sectionsAround(y int) SectionSet {
+	i, sectionY := sectionOf(y)
+	s := SectionSet(1) << i
+	if sectionY == 0 && i > 0 {
+		s |= 1 << (i - 1)
+	}
+	if sectionY == SECTION_HEIGHT-1 && i < SECTION_COUNT-1 {
+		s |= 1 << (i + 1)
+	}
+	return s
+}
+
+// sideOffsets are the offsets of the chunks sharing a side with a chunk
+var sideOffsets = [4]utils.IntVector2{{X: -1, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: -1}, {X: 0, Y: 1}}
+
+// borderOffsets are the offsets of the chunks around a chunk, the ones at sideOffsets first, then the ones sharing a corner with it
+var borderOffsets = [8]utils.IntVector2{
+	{X: -1, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: -1}, {X: 0, Y: 1},
+	{X: -1, Y: -1}, {X: 1, Y: -1}, {X: -1, Y: 1}, {X: 1, Y: 1},
+}
+
+// borderSections holds sections of the chunks at borderOffsets from a chunk
+type borderSections [len(borderOffsets)]SectionSet
+
+// allBorderSections are all the sections of the chunks around a chunk
+var allBorderSections = borderSections{allSections, allSections, allSections, allSections, allSections, allSections, allSections, allSections}
+
+/*
+addBorderSections adds to borders the sections of the chunks at borderOffsets whose mesh shows the block at p, in chunk coordinates.
+The ambient occlusion of the blocks touching it diagonally depends on it too, so the chunks sharing a corner with it are included.
+*/
+func addBorderSections(borders *borderSections, p utils.IntVector3) {
+	sections := sectionsAround(p.Y)
+	touches := func(offset, coordinate int) bool {
+		return offset == 0 || offset < 0 && coordinate == 0 || offset > 0 && coordinate == CHUNK_WIDTH-1
+	}
+	for i, offset := range borderOffsets {
+		if touches(offset.X, p.X) && touches(offset.Y, p.Z) {
+			borders[i] |= sections
+		}
+	}
 }
 
 type chunkSnapshot struct {
 	coordinates utils.IntVector2
-	blocks      [CHUNK_WIDTH][CHUNK_HEIGHT][CHUNK_WIDTH]BlockId
-	observer    utils.IntVector3
+	blocks      chunkStorage
+	light       chunkLight
+	dirty       SectionSet // sections to mesh
 }
 
 // Using the exported members of Chunk is fully thread safe
 type Chunk struct {
-	mu            sync.Mutex
-	meshBuilder   *meshBuilder
-	coordinates   utils.IntVector2
-	observer      *atomicx.Value[LevelObserver] // Coordinates of the block closest to the level observer in the chunk
-	observerCache utils.IntVector3
-	blocks        [CHUNK_WIDTH][CHUNK_HEIGHT][CHUNK_WIDTH]BlockId
-	Slot          int
+	mu          sync.Mutex
+	meshBuilder *meshBuilder
+	coordinates utils.IntVector2
+	blocks      chunkStorage
+	light       chunkLight  // only valid once status reaches STATUS_LIGHT
+	status      ChunkStatus // generation stage the blocks of coordinates reached
+	dirty       bool        // blocks changed since they were last loaded or saved
+	Slot        int
 
-	Mesh        *atomicx.Value[ChunkMesh]
-	MeshUpdates chan struct{}
+	dirtySections SectionSet // sections whose mesh is outdated
+
+	meshes        [SECTION_COUNT]atomicx.Value[ChunkMesh]
+	updatedMeshes atomic.Uint32 // SectionSet of the meshes changed since TakeMeshUpdates was last called
 }
 
-func newChunk(meshBuilder *meshBuilder, observer *atomicx.Value[LevelObserver]) *Chunk {
+func newChunk(meshBuilder *meshBuilder) *Chunk {
 	c := &Chunk{
 		meshBuilder: meshBuilder,
-		observer:    observer,
-		Mesh:        &atomicx.Value[ChunkMesh]{},
-		MeshUpdates: make(chan struct{}, 1),
 	}
-
-	go func() {
-		for {
-			c.updateObserverCache()
-			time.Sleep(500 * time.Millisecond)
-		}
-	}()
+	// sections that weren't meshed yet don't hide the ones behind them
+	for i := range c.meshes {
+		c.meshes[i].Store(ChunkMesh{Visibility: allVisible})
+	}
 	return c
 }
 
@@ -61,137 +148,376 @@ func (c *Chunk) snapshot() chunkSnapshot {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	dirty := c.dirtySections
+	c.dirtySections = 0
 	return chunkSnapshot{
 		coordinates: c.coordinates,
-		blocks:      c.blocks,
-		observer:    c.observerCache,
+		blocks:      c.blocks.share(),
+		light:       c.light.share(),
+		dirty:       dirty,
 	}
 }
 
-func (c *chunkSnapshot) iter() iter.Seq2[utils.IntVector3, BlockId] {
+// iter returns the blocks of section i, y is relative to the section
+func (c *chunkSnapshot) iter(i int) iter.Seq2[utils.IntVector3, BlockId] {
+	bottom := SectionBottom(i)
 	return func(yield func(utils.IntVector3, BlockId) bool) {
-		for pos, b := range utils.UnsafeFromOriginIterator3(
-			&c.blocks[0][0][0],
-			utils.IntVector3{X: c.coordinates.X * CHUNK_WIDTH, Y: 0, Z: c.coordinates.Y * CHUNK_WIDTH},                        // Start
-			utils.IntVector3{X: (c.coordinates.X + 1) * CHUNK_WIDTH, Y: CHUNK_HEIGHT, Z: (c.coordinates.Y + 1) * CHUNK_WIDTH}, // End
-			utils.IntVector3{X: CHUNK_WIDTH, Y: CHUNK_HEIGHT, Z: CHUNK_WIDTH},                                                 // Size
-			c.observer, // Origin
-		) {
-
-			if !yield(pos, b) {
-				return
+		for x := range CHUNK_WIDTH {
+			for y := range SECTION_HEIGHT {
+				for z := range CHUNK_WIDTH {
+					if !yield(utils.IntVector3{X: x, Y: y, Z: z}, c.blocks.get(x, bottom+y, z)) {
+						return
+					}
+				}
 			}
 		}
 	}
 }
 
-func (c *Chunk) setCoordinates(coordinates utils.IntVector2) {
+func (c *Chunk) getCoordinates() utils.IntVector2 {
 	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.coordinates
+}
+
+// setCoordinates moves the chunk to coordinates, saving its previous content to storage if it was modified
+func (c *Chunk) setCoordinates(coordinates utils.IntVector2, storage *WorldStorage) error {
+	c.mu.Lock()
+	err := c.saveLocked(storage)
 	c.coordinates = coordinates
+	c.status = STATUS_EMPTY
+	c.dirty = false
+	c.dirtySections = allSections
 	c.mu.Unlock()
 
 	c.meshBuilder.enqueue(c)
+	if err != nil {
+		return fmt.Errorf("saveLocked(): %w", err)
+	}
+	return nil
+}
+
+// Status returns the generation stage the chunk reached, only STATUS_FULL chunks are meshed
+func (c *Chunk) Status() ChunkStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.status
+}
+
+// statusAt returns the status of the chunk if it is at coordinates, STATUS_EMPTY otherwise
+func (c *Chunk) statusAt(coordinates utils.IntVector2) ChunkStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.coordinates != coordinates {
+		return STATUS_EMPTY
+	}
+	return c.status
+}
+
+// blocksAt returns a copy of the blocks of the chunk, ok is false if it isn't at coordinates anymore
+func (c *Chunk) blocksAt(coordinates utils.IntVector2) (blocks chunkBlocks, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.coordinates != coordinates {
+		return blocks, false
+	}
+	return *c.blocks.dense(), true
+}
+
+/*
+setBlocks brings the chunk to status, replacing its blocks unless blocks is nil.
+It returns false if the chunk moved or already reached status while the blocks were being generated.
+dirty tells whether the blocks differ from the saved ones.
+*/
+func (c *Chunk) setBlocks(coordinates utils.IntVector2, status ChunkStatus, blocks *chunkBlocks, dirty bool) bool {
+	c.mu.Lock()
+	if c.coordinates != coordinates || c.status >= status {
+		c.mu.Unlock()
+		return false
+	}
+	if blocks != nil {
+		c.blocks = newChunkStorage(blocks)
+	}
+	c.status = status
+	c.dirty = c.dirty || dirty
+	if status == STATUS_FULL {
+		c.dirtySections = allSections
+	}
+	c.mu.Unlock()
+
+	if status == STATUS_FULL {
+		c.meshBuilder.enqueue(c)
+		// the neighbours were meshed with their faces towards this chunk hidden
+		c.meshBuilder.level.remeshBorders(coordinates, allBorderSections)
+	}
+	return true
 }
 
-func (c *Chunk) setBlocks(blocks [CHUNK_WIDTH][CHUNK_HEIGHT][CHUNK_WIDTH]BlockId) {
+// applyWrites merges blocks placed by the features of another chunk, if the chunk is at coordinates and already placed its own
+func (c *Chunk) applyWrites(coordinates utils.IntVector2, writes []blockWrite) bool {
 	c.mu.Lock()
-	c.blocks = blocks
+	if c.coordinates != coordinates || c.status < STATUS_FEATURES {
+		c.mu.Unlock()
+		return false
+	}
+	c.blocks.mergeWrites(writes)
+	c.dirty = true
+	var borders borderSections
+	for _, w := range writes {
+		c.dirtySections |= sectionsAround(w.position.Y)
+		addBorderSections(&borders, w.position)
+	}
 	c.mu.Unlock()
 
 	c.meshBuilder.enqueue(c)
+	c.meshBuilder.level.remeshBorders(coordinates, borders)
+	return true
 }
 
-func visibleOrDifferentHeightLevel(a, b BlockId) bool {
-	return visible(a, b) || BLOCK_TYPES[a].height != BLOCK_TYPES[b].height
+// computeLight replaces the light of the chunk by the one of its own blocks, ok is false if it isn't at coordinates or is already lit
+func (c *Chunk) computeLight(coordinates utils.IntVector2) (sources [lightChannels][]utils.IntVector3, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.coordinates != coordinates || c.status >= STATUS_LIGHT {
+		return sources, false
+	}
+	c.light, sources = newChunkLight(&c.blocks)
+	return sources, true
 }
 
-func (c *Chunk) generateMesh(level *Level) {
-	snap := c.snapshot()
-	mesh := ChunkMesh{make([]uint32, 0), make([]uint32, 0)}
+// lightAt returns the light and the block at p in the chunk, ok is false if it isn't at coordinates or isn't lit, unless lighting is true
+func (c *Chunk) lightAt(coordinates utils.IntVector2, p utils.IntVector3, lighting bool) (value light, b BlockId, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	for pos, b := range snap.iter() {
-		if b == AIR {
-			continue
-		}
-		x := pos.X
-		y := pos.Y
-		z := pos.Z
-
-		render := [6]bool{}
-
-		// (middle AND visible) OR edge
-		render[0] = y+1 < CHUNK_HEIGHT && visible(snap.blocks[x][y+1][z], b) || y+1 >= CHUNK_HEIGHT
-		render[1] = y-1 >= 0 && visible(snap.blocks[x][y-1][z], b) || y-1 < 0
-
-		// visible OR different height level
-		levelX := snap.coordinates.X*CHUNK_WIDTH + x
-		levelZ := snap.coordinates.Y*CHUNK_WIDTH + z
-		if x-1 >= 0 {
-			render[2] = visibleOrDifferentHeightLevel(snap.blocks[x-1][y][z], b)
-		} else if a, ok := level.getBlockPosition(mgl32.Vec3{float32(levelX - 1), float32(y), float32(levelZ)}).Get(); ok {
-			render[2] = visibleOrDifferentHeightLevel(a, b)
+	if c.coordinates != coordinates || c.status < STATUS_LIGHT && !lighting {
+		return 0, AIR, false
+	}
+	return c.light.get(p.X, p.Y, p.Z), c.blocks.get(p.X, p.Y, p.Z), true
+}
+
+// setLight sets the light at p in the chunk, it returns false if it isn't at coordinates or isn't lit, unless lighting is true
+func (c *Chunk) setLight(coordinates utils.IntVector2, p utils.IntVector3, value light, lighting bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.coordinates != coordinates || c.status < STATUS_LIGHT && !lighting {
+		return false
+	}
+	c.light.set(p.X, p.Y, p.Z, value)
+	return true
+}
+
+// invalidateMeshes remeshes sections of the chunk if it is fully generated at coordinates
+func (c *Chunk) invalidateMeshes(coordinates utils.IntVector2, sections SectionSet) {
+	c.mu.Lock()
+	if c.coordinates != coordinates || c.status != STATUS_FULL {
+		c.mu.Unlock()
+		return
+	}
+	c.dirtySections |= sections
+	c.mu.Unlock()
+
+	c.meshBuilder.enqueue(c)
+}
+
+// save writes the chunk to storage if it was modified since it was last loaded or saved
+func (c *Chunk) save(storage *WorldStorage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.saveLocked(storage)
+}
+
+func (c *Chunk) saveLocked(storage *WorldStorage) error {
+	if c.status == STATUS_EMPTY || !c.dirty {
+		return nil
+	}
+
+	err := storage.saveChunk(c.coordinates, c.status, c.blocks.dense())
+	if err != nil {
+		return fmt.Errorf("saveChunk(): %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// blockAt returns the block at x, y, z relative to the chunk, which may be in the chunks around, loaded is false if its chunk isn't loaded
+func (c *chunkSnapshot) blockAt(level *Level, x, y, z int) (b BlockId, loaded bool) {
+	switch {
+	case y < MIN_Y || y >= MAX_Y:
+		return AIR, true
+	case x >= 0 && x < CHUNK_WIDTH && z >= 0 && z < CHUNK_WIDTH:
+		return c.blocks.get(x, y, z), true
+	default:
+		levelX := c.coordinates.X*CHUNK_WIDTH + x
+		levelZ := c.coordinates.Y*CHUNK_WIDTH + z
+		return level.blockAt(utils.IntVector3{X: levelX, Y: y, Z: levelZ})
+	}
+}
+
+// neighbours returns the blocks around the block at x, y, z in the chunk, indexed by face, loaded is false for the ones in chunks that aren't loaded
+func (c *chunkSnapshot) neighbours(level *Level, x, y, z int) (neighbours [6]BlockId, loaded [6]bool) {
+	for face, normal := range faceNormals {
+		neighbours[face], loaded[face] = c.blockAt(level, x+normal.X, y+normal.Y, z+normal.Z)
+	}
+	return neighbours, loaded
+}
+
+// occluders returns which of the 26 blocks around the block at x, y, z in the chunk are opaque, the ones in chunks that aren't loaded aren't
+func (c *chunkSnapshot) occluders(level *Level, x, y, z int) blockOccluders {
+	var o blockOccluders
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for dz := -1; dz <= 1; dz++ {
+				offset := utils.IntVector3{X: dx, Y: dy, Z: dz}
+				if b, _ := c.blockAt(level, x+dx, y+dy, z+dz); offset != (utils.IntVector3{}) && b.opaque() {
+					o.add(offset)
+				}
+			}
 		}
+	}
+	return o
+}
+
+// lightAt returns the light of the block at x, y, z relative to the chunk, which may be in the chunks around
+func (c *chunkSnapshot) lightAt(level *Level, x, y, z int) light {
+	switch {
+	case y >= MAX_Y:
+		return skyLight
+	case y < MIN_Y:
+		return 0
+	case x >= 0 && x < CHUNK_WIDTH && z >= 0 && z < CHUNK_WIDTH:
+		return c.light.get(x, y, z)
+	default:
+		levelX := c.coordinates.X*CHUNK_WIDTH + x
+		levelZ := c.coordinates.Y*CHUNK_WIDTH + z
+		l, _ := level.lightAt(utils.IntVector3{X: levelX, Y: y, Z: levelZ})
+		return l
+	}
+}
 
-		if x+1 < CHUNK_WIDTH {
-			render[3] = visibleOrDifferentHeightLevel(snap.blocks[x+1][y][z], b)
-		} else if a, ok := level.getBlockPosition(mgl32.Vec3{float32(levelX + 1), float32(y), float32(levelZ)}).Get(); ok {
-			render[3] = visibleOrDifferentHeightLevel(a, b)
+// lights returns the light of the block at x, y, z in the chunk and of the 26 blocks around
+func (c *chunkSnapshot) lights(level *Level, x, y, z int) blockLights {
+	var l blockLights
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for dz := -1; dz <= 1; dz++ {
+				l[blockLightIndex(utils.IntVector3{X: dx, Y: dy, Z: dz})] = c.lightAt(level, x+dx, y+dy, z+dz)
+			}
 		}
+	}
+	return l
+}
 
-		if z+1 < CHUNK_WIDTH {
-			render[4] = visibleOrDifferentHeightLevel(snap.blocks[x][y][z+1], b)
-		} else if a, ok := level.getBlockPosition(mgl32.Vec3{float32(levelX), float32(y), float32(levelZ + 1)}).Get(); ok {
-			render[4] = visibleOrDifferentHeightLevel(a, b)
+// generateMesh meshes the dirty sections of the chunk
+func (c *Chunk) generateMesh(level *Level) {
+	snap := c.snapshot()
+
+	for i := range SECTION_COUNT {
+		if !snap.dirty.Has(i) {
+			continue
 		}
+		c.meshes[i].Store(snap.meshSection(level, i))
+	}
+	c.updatedMeshes.Or(uint32(snap.dirty))
+}
 
-		if z-1 >= 0 {
-			render[5] = visibleOrDifferentHeightLevel(snap.blocks[x][y][z-1], b)
-		} else if a, ok := level.getBlockPosition(mgl32.Vec3{float32(levelX), float32(y), float32(levelZ - 1)}).Get(); ok {
-			render[5] = visibleOrDifferentHeightLevel(a, b)
+func (c *chunkSnapshot) meshSection(level *Level, i int) ChunkMesh {
+	mesh := ChunkMesh{make([]uint32, 0), make([]uint32, 0), allVisible}
+	if c.blocks.sections[i] == nil {
+		return mesh
+	}
+	mesh.Visibility = c.blocks.sections[i].visibility()
+
+	bottom := SectionBottom(i)
+	merged := newGreedyMesher()
+	for pos, b := range c.iter(i) {
+		if b == AIR {
+			continue
 		}
 
-		if BLOCK_TYPES[b].isTransparent {
-			mesh.Transparent = append(mesh.Transparent, b.mesh(x, y, z, render)...)
-		} else {
-			mesh.Solid = append(mesh.Solid, b.mesh(x, y, z, render)...)
+		s := b.surroundings(c.neighbours(level, pos.X, bottom+pos.Y, pos.Z))
+		transparent := BLOCK_TYPES[b.Type()].isTransparent
+		// looked up with the first quad that needs them
+		var occluders *blockOccluders
+		var lights *blockLights
+		for q := range b.visibleQuads(s) {
+			if lights == nil {
+				l := c.lights(level, pos.X, bottom+pos.Y, pos.Z)
+				lights = &l
+			}
+			light := flatLight(q.light(lights))
+			var occlusion quadOcclusion
+			if q.occluded() {
+				if occluders == nil {
+					o := c.occluders(level, pos.X, bottom+pos.Y, pos.Z)
+					occluders = &o
+				}
+				occlusion = q.occlusion(*occluders)
+				light = q.smoothLight(*occluders, lights)
+			}
+			switch {
+			case merged.add(q, pos.X, pos.Y, pos.Z, transparent, light, occlusion):
+			case transparent:
+				mesh.Transparent = appendQuad(mesh.Transparent, q, pos.X, pos.Y, pos.Z, light, occlusion)
+			default:
+				mesh.Solid = appendQuad(mesh.Solid, q, pos.X, pos.Y, pos.Z, light, occlusion)
+			}
 		}
 	}
-
-	c.Mesh.Store(mesh)
-	chanx.TrySend(c.MeshUpdates, struct{}{})
+	merged.appendTo(&mesh)
+	return mesh
 }
 
 func (c *Chunk) clearMesh() {
-	c.Mesh.Store(ChunkMesh{make([]uint32, 0), make([]uint32, 0)})
-	chanx.TrySend(c.MeshUpdates, struct{}{})
+	for i := range c.meshes {
+		c.meshes[i].Store(ChunkMesh{make([]uint32, 0), make([]uint32, 0), allVisible})
+	}
+	c.updatedMeshes.Store(uint32(allSections))
 }
 
-func (c *Chunk) updateObserverCache() {
-	observer := c.observer.Load().Vec3
-
-	newObserverCache := utils.IntVector3{
-		X: int(math.Floor(math.Max(math.Min(float64(observer.X()), float64((c.coordinates.X+1)*CHUNK_WIDTH)), float64(c.coordinates.X*CHUNK_WIDTH)))),
-		Y: int(math.Floor(math.Max(math.Min(float64(observer.Y()), CHUNK_HEIGHT), 0))),
-		Z: int(math.Floor(math.Max(math.Min(float64(observer.Z()), float64((c.coordinates.Y+1)*CHUNK_WIDTH)), float64(c.coordinates.Y*CHUNK_WIDTH)))),
-	}
+// SectionMesh returns the last mesh of section i
+func (c *Chunk) SectionMesh(i int) ChunkMesh {
+	return c.meshes[i].Load()
+}
 
-	if c.observerCache != newObserverCache {
-		c.observerCache = newObserverCache
-		c.meshBuilder.enqueue(c) // TODO optimize to not rebuild meshes on Y change if not at a height with transparent blocks
-	}
+// TakeMeshUpdates returns the sections whose mesh changed since it was last called
+func (c *Chunk) TakeMeshUpdates() SectionSet {
+	return SectionSet(c.updatedMeshes.Swap(0))
 }
 
 func (c *Chunk) getBlock(coordinates utils.IntVector3) BlockId {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	return c.blocks[coordinates.X][coordinates.Y][coordinates.Z]
+	return c.blocks.get(coordinates.X, coordinates.Y, coordinates.Z)
+}
+
+func (c *Chunk) getLight(coordinates utils.IntVector3) light {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.light.get(coordinates.X, coordinates.Y, coordinates.Z)
 }
 
 func (c *Chunk) setBlock(coordinates utils.IntVector3, value BlockId) {
 	c.mu.Lock()
-	c.blocks[coordinates.X][coordinates.Y][coordinates.Z] = value
+	old := c.blocks.get(coordinates.X, coordinates.Y, coordinates.Z)
+	c.blocks.set(coordinates.X, coordinates.Y, coordinates.Z, value)
+	c.dirty = true
+	c.dirtySections |= sectionsAround(coordinates.Y)
+	chunkCoordinates := c.coordinates
 	c.mu.Unlock()
 
 	c.meshBuilder.enqueue(c)
+	var borders borderSections
+	addBorderSections(&borders, coordinates)
+	c.meshBuilder.level.remeshBorders(chunkCoordinates, borders)
+	c.meshBuilder.level.updateLight(chunkCoordinates, coordinates, old, value)
 }
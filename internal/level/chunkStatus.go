@@ -0,0 +1,51 @@
+package level
+
+import "github.com/vparent05/minecraft_go/internal/utils"
+
+// ChunkStatus is the last generation stage a chunk went through
+type ChunkStatus uint8
+
+const (
+	STATUS_EMPTY    ChunkStatus = iota // nothing generated yet
+	STATUS_TERRAIN                     // terrain, seas and surface blocks
+	STATUS_CARVERS                     // caves dug out of the terrain
+	STATUS_FEATURES                    // trees, plants and other features placed, pending writes merged
+	STATUS_LIGHT                       // light computed
+	STATUS_FULL                        // ready to be meshed and played in
+)
+
+var chunkStatusNames = [...]string{
+	STATUS_EMPTY:    "empty",
+	STATUS_TERRAIN:  "terrain",
+	STATUS_CARVERS:  "carvers",
+	STATUS_FEATURES: "features",
+	STATUS_LIGHT:    "light",
+	STATUS_FULL:     "full",
+}
+
+func (s ChunkStatus) String() string {
+	if int(s) < len(chunkStatusNames) {
+		return chunkStatusNames[s]
+	}
+	return "unknown"
+}
+
+/*
+generationStage brings a chunk from one status to the next.
+A stage only runs once the 8 chunks around have reached its neighbours status,
+so it can rely on what their previous stages put in or around them.
+run returns false if the chunk moved or was advanced by another worker meanwhile.
+*/
+type generationStage struct {
+	neighbours ChunkStatus
+	run        func(w *worldGenerator, chunk *Chunk, coordinates utils.IntVector2) bool
+}
+
+// generationStages is indexed by the status a chunk has before the stage
+var generationStages = [...]generationStage{
+	STATUS_EMPTY:    {STATUS_EMPTY, (*worldGenerator).generateTerrain},
+	STATUS_TERRAIN:  {STATUS_EMPTY, (*worldGenerator).carve},
+	STATUS_CARVERS:  {STATUS_EMPTY, (*worldGenerator).placeFeatures},
+	STATUS_FEATURES: {STATUS_FEATURES, (*worldGenerator).light}, // features spill into the neighbours
+	STATUS_LIGHT:    {STATUS_LIGHT, (*worldGenerator).finish},   // light spreads across chunk borders
+}
@@ -0,0 +1,255 @@
+package level
+
+import (
+	"math/bits"
+	"slices"
+	"unsafe"
+)
+
+const SECTION_HEIGHT = 16
+
+// SECTION_COUNT is the number of sections of a chunk, the lowest one starts at MIN_Y
+const SECTION_COUNT = CHUNK_HEIGHT / SECTION_HEIGHT
+const sectionVolume = CHUNK_WIDTH * SECTION_HEIGHT * CHUNK_WIDTH
+
+/*
+blockSection holds SECTION_HEIGHT layers of a chunk as the indices of its blocks in a palette of the blocks it contains.
+The indices take bits bits each and are packed in words of 64 bits, an index never spans 2 words.
+A section of a single block has no data.
+*/
+type blockSection struct {
+	palette []BlockId
+	bits    int
+	data    []uint64
+	nonAir  int // number of blocks that aren't AIR
+}
+
+// sectionIndex returns the index of the block at x, y, z in a section, y relative to the section
+func sectionIndex(x, y, z int) int {
+	return (x*SECTION_HEIGHT+y)*CHUNK_WIDTH + z
+}
+
+// paletteBits returns the bits needed to index a palette of size blocks
+func paletteBits(size int) int {
+	return bits.Len(uint(size - 1))
+}
+
+func newSectionData(bits int) []uint64 {
+	if bits == 0 {
+		return nil
+	}
+	perWord := 64 / bits
+	return make([]uint64, (sectionVolume+perWord-1)/perWord)
+}
+
+// newBlockSection returns section i of blocks, nil if it is only air
+func newBlockSection(blocks *chunkBlocks, i int) *blockSection {
+	s := &blockSection{}
+	indices := make(map[BlockId]int)
+	layer := i * SECTION_HEIGHT
+	for x := range CHUNK_WIDTH {
+		for j := range SECTION_HEIGHT {
+			for z := range CHUNK_WIDTH {
+				b := blocks[x][layer+j][z]
+				if _, ok := indices[b]; !ok {
+					indices[b] = len(s.palette)
+					s.palette = append(s.palette, b)
+				}
+				if b != AIR {
+					s.nonAir++
+				}
+			}
+		}
+	}
+	if s.nonAir == 0 {
+		return nil
+	}
+
+	s.bits = paletteBits(len(s.palette))
+	s.data = newSectionData(s.bits)
+	if s.bits == 0 {
+		return s
+	}
+	for x := range CHUNK_WIDTH {
+		for j := range SECTION_HEIGHT {
+			for z := range CHUNK_WIDTH {
+				s.setIndex(sectionIndex(x, j, z), indices[blocks[x][layer+j][z]])
+			}
+		}
+	}
+	return s
+}
+
+func (s *blockSection) index(i int) int {
+	if s.bits == 0 {
+		return 0
+	}
+	perWord := 64 / s.bits
+	return int(s.data[i/perWord] >> (i % perWord * s.bits) & (1<<s.bits - 1))
+}
+
+func (s *blockSection) setIndex(i, index int) {
+	perWord := 64 / s.bits
+	shift := i % perWord * s.bits
+	word := &s.data[i/perWord]
+	*word = *word&^((1<<s.bits-1)<<shift) | uint64(index)<<shift
+}
+
+func (s *blockSection) get(i int) BlockId {
+	return s.palette[s.index(i)]
+}
+
+// set replaces the block at i, the palette only grows so the indices of the other blocks stay valid
+func (s *blockSection) set(i int, b BlockId) {
+	current := s.get(i)
+	if current == b {
+		return
+	}
+	if current == AIR {
+		s.nonAir++
+	} else if b == AIR {
+		s.nonAir--
+	}
+
+	index := slices.Index(s.palette, b)
+	if index < 0 {
+		index = len(s.palette)
+		s.palette = append(s.palette, b)
+		if bits := paletteBits(len(s.palette)); bits > s.bits {
+			s.resize(bits)
+		}
+	}
+	s.setIndex(i, index)
+}
+
+// resize repacks the indices on bits bits
+func (s *blockSection) resize(bits int) {
+	previous := *s
+	s.bits = bits
+	s.data = newSectionData(bits)
+	if previous.bits == 0 {
+		return // every index was 0
+	}
+	for i := range sectionVolume {
+		s.setIndex(i, previous.index(i))
+	}
+}
+
+func (s *blockSection) clone() *blockSection {
+	return &blockSection{slices.Clone(s.palette), s.bits, slices.Clone(s.data), s.nonAir}
+}
+
+/*
+sharedSections holds the sections of a chunk, which copies of it can use at the same time.
+A section is copied by the first of them changing it, so the others keep seeing it as it was.
+*/
+type sharedSections[S any] struct {
+	sections [SECTION_COUNT]*S
+	shared   [SECTION_COUNT]bool
+}
+
+// markShared marks every section as used by a copy of s
+func (s *sharedSections[S]) markShared() {
+	for i, section := range s.sections {
+		s.shared[i] = section != nil
+	}
+}
+
+// own makes section i used by s only, copying it with clone if it is shared
+func (s *sharedSections[S]) own(i int, clone func(*S) *S) {
+	if s.shared[i] {
+		s.sections[i] = clone(s.sections[i])
+	}
+	s.shared[i] = false
+}
+
+// chunkStorage holds the blocks of a chunk in sections, nil for the sections that are only air
+type chunkStorage struct {
+	sharedSections[blockSection]
+}
+
+func newChunkStorage(blocks *chunkBlocks) chunkStorage {
+	var c chunkStorage
+	for i := range c.sections {
+		c.sections[i] = newBlockSection(blocks, i)
+	}
+	return c
+}
+
+// sectionOf returns the section of the blocks at y and their y relative to it
+func sectionOf(y int) (section, sectionY int) {
+	return (y - MIN_Y) / SECTION_HEIGHT, (y - MIN_Y) % SECTION_HEIGHT
+}
+
+// SectionBottom returns the y of the lowest layer of section i
+func SectionBottom(i int) int {
+	return MIN_Y + i*SECTION_HEIGHT
+}
+
+// get returns the block at x, y, z in the chunk, y goes from MIN_Y to MAX_Y
+func (c *chunkStorage) get(x, y, z int) BlockId {
+	i, sectionY := sectionOf(y)
+	s := c.sections[i]
+	if s == nil {
+		return AIR
+	}
+	return s.get(sectionIndex(x, sectionY, z))
+}
+
+func (c *chunkStorage) set(x, y, z int, b BlockId) {
+	i, sectionY := sectionOf(y)
+	switch {
+	case c.sections[i] == nil && b == AIR:
+		return
+	case c.sections[i] == nil:
+		c.sections[i] = &blockSection{palette: []BlockId{AIR}}
+	}
+	c.own(i, (*blockSection).clone)
+
+	s := c.sections[i]
+	s.set(sectionIndex(x, sectionY, z), b)
+	if s.nonAir == 0 {
+		c.sections[i] = nil
+	}
+}
+
+// share returns a copy of c using the same sections
+func (c *chunkStorage) share() chunkStorage {
+	c.markShared()
+	return *c
+}
+
+func (c *chunkStorage) dense() *chunkBlocks {
+	var blocks chunkBlocks
+	for i, s := range c.sections {
+		if s == nil {
+			continue
+		}
+		for x := range CHUNK_WIDTH {
+			for y := range SECTION_HEIGHT {
+				for z := range CHUNK_WIDTH {
+					blocks[x][i*SECTION_HEIGHT+y][z] = s.get(sectionIndex(x, y, z))
+				}
+			}
+		}
+	}
+	return &blocks
+}
+
+func (c *chunkStorage) mergeWrites(writes []blockWrite) {
+	for _, w := range writes {
+		p := w.position
+		c.set(p.X, p.Y, p.Z, mergeFeatureBlock(c.get(p.X, p.Y, p.Z), w.block))
+	}
+}
+
+// size returns the number of bytes used by the storage and its sections
+func (c *chunkStorage) size() int {
+	size := int(unsafe.Sizeof(*c))
+	for _, s := range c.sections {
+		if s != nil {
+			size += int(unsafe.Sizeof(*s)) + cap(s.palette)*int(unsafe.Sizeof(AIR)) + cap(s.data)*8
+		}
+	}
+	return size
+}
@@ -0,0 +1,102 @@
+package level
+
+import (
+	"math/rand"
+	"testing"
+	"unsafe"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+func TestChunkStorageMatchesDense(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var dense chunkBlocks
+	storage := newChunkStorage(&dense)
+
+	// more blocks than fit in 8 bits of palette, most of them in the first section
+	for i := range 20000 {
+		x, layer, z := rng.Intn(CHUNK_WIDTH), rng.Intn(SECTION_HEIGHT), rng.Intn(CHUNK_WIDTH)
+		if i%4 == 0 {
+			layer = rng.Intn(CHUNK_HEIGHT)
+		}
+		b := BlockId(rng.Intn(600))
+		dense[x][layer][z] = b
+		storage.set(x, MIN_Y+layer, z, b)
+	}
+	if *storage.dense() != dense {
+		t.Fatal("the storage differs from the dense blocks after random writes")
+	}
+	if compressed := newChunkStorage(&dense); *compressed.dense() != dense {
+		t.Fatal("the storage built from dense blocks differs from them")
+	}
+
+	// a snapshot keeps its blocks while the chunk changes
+	snapshot := storage.share()
+	storage.set(1, MIN_Y+2, 3, STONE)
+	storage.set(4, 200, 5, STONE)
+	if snapshot.get(1, MIN_Y+2, 3) != dense[1][2][3] || snapshot.get(4, 200, 5) != dense[4][200-MIN_Y][5] {
+		t.Error("changing the storage changed its snapshot")
+	}
+	if storage.get(1, MIN_Y+2, 3) != STONE {
+		t.Error("the storage lost a change made after a snapshot")
+	}
+
+	// sections emptied back to air are dropped
+	for x := range CHUNK_WIDTH {
+		for y := range SECTION_HEIGHT {
+			for z := range CHUNK_WIDTH {
+				storage.set(x, MIN_Y+y, z, AIR)
+			}
+		}
+	}
+	if storage.sections[0] != nil {
+		t.Error("an all air section is kept")
+	}
+}
+
+func generatedStorage(b *testing.B) chunkStorage {
+	b.Helper()
+	return newChunkStorage(generate(NewNoiseGenerator(42), utils.IntVector2{X: 3, Y: -2}))
+}
+
+var benchmarkBlocks *chunkBlocks
+var benchmarkStorage chunkStorage
+
+// BenchmarkChunkMemory compares storing a generated chunk as a dense array, as before, and in sections
+func BenchmarkChunkMemory(b *testing.B) {
+	storage := generatedStorage(b)
+	dense := storage.dense()
+	b.Run("dense", func(b *testing.B) {
+		for b.Loop() {
+			benchmarkBlocks = new(chunkBlocks)
+			*benchmarkBlocks = *dense
+		}
+		b.ReportMetric(float64(unsafe.Sizeof(*dense)), "bytes/chunk")
+	})
+	b.Run("sections", func(b *testing.B) {
+		for b.Loop() {
+			benchmarkStorage = newChunkStorage(dense)
+		}
+		b.ReportMetric(float64(benchmarkStorage.size()), "bytes/chunk")
+	})
+}
+
+// BenchmarkChunkSnapshot compares the copy the mesher took of a dense chunk, as before, with a shared snapshot
+func BenchmarkChunkSnapshot(b *testing.B) {
+	storage := generatedStorage(b)
+	dense := storage.dense()
+	b.Run("dense", func(b *testing.B) {
+		for b.Loop() {
+			benchmarkBlocks = new(chunkBlocks)
+			*benchmarkBlocks = *dense
+		}
+	})
+	b.Run("sections", func(b *testing.B) {
+		for b.Loop() {
+			benchmarkStorage = storage.share()
+			// the first change after a snapshot copies the section
+			storage.set(7, 60, 7, STONE)
+			storage.set(7, 60, 7, AIR)
+		}
+	})
+}
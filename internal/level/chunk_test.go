@@ -0,0 +1,181 @@
+package level
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+	"github.com/vparent05/minecraft_go/internal/utils/atomicx"
+)
+
+func TestSetBlockRemeshesItsSections(t *testing.T) {
+	observer := &atomicx.Value[LevelObserver]{}
+	observer.Store(LevelObserver{})
+	level := &Level{observer: observer}
+	c := newChunk(newMeshBuilder(level, 1))
+	c.status = STATUS_FULL
+
+	// away from the chunk borders, its neighbours are never looked up
+	c.setBlock(utils.IntVector3{X: 7, Y: SectionBottom(3) + 5, Z: 7}, STONE)
+	if c.dirtySections != 1<<3 {
+		t.Errorf("dirty sections are %b, want only section 3", c.dirtySections)
+	}
+	c.generateMesh(level)
+	if updated := c.TakeMeshUpdates(); updated != 1<<3 {
+		t.Errorf("updated meshes are %b, want only section 3", updated)
+	}
+	if mesh := c.SectionMesh(3); Quads(mesh.Solid) != 6 {
+		t.Errorf("section 3 has %d solid quads, want the 6 faces of a block", Quads(mesh.Solid))
+	}
+
+	// a block on the border of a section shows in the mesh of the section next to it
+	c.setBlock(utils.IntVector3{X: 7, Y: SectionBottom(3), Z: 7}, STONE)
+	if c.dirtySections != 1<<2|1<<3 {
+		t.Errorf("dirty sections are %b, want sections 2 and 3", c.dirtySections)
+	}
+
+	// blocks below y = 0 are kept
+	c.setBlock(utils.IntVector3{X: 7, Y: MIN_Y, Z: 7}, STONE)
+	if c.getBlock(utils.IntVector3{X: 7, Y: MIN_Y, Z: 7}) != STONE {
+		t.Error("the block at the bottom of the level is lost")
+	}
+}
+
+// newTestLevel returns a level with empty chunk slots around the origin, its chunks are meshed by calling generateMesh
+func newTestLevel(renderDistance int) (*Level, *meshBuilder) {
+	observer := &atomicx.Value[LevelObserver]{}
+	observer.Store(LevelObserver{RenderDistance: renderDistance})
+	l := NewLevel(observer, nil, nil)
+	l.chunks = make([][]*Chunk, 2*renderDistance+1)
+	for i := range l.chunks {
+		l.chunks[i] = make([]*Chunk, 2*renderDistance+1)
+	}
+	return l, newMeshBuilder(l, renderDistance)
+}
+
+func addTestChunk(l *Level, m *meshBuilder, coordinates utils.IntVector2) *Chunk {
+	c := newChunk(m)
+	c.setCoordinates(coordinates, nil)
+	l.setChunk(coordinates, c)
+	return c
+}
+
+// rightBorderArea returns the area in blocks of the faces of the chunk drawn on its +x border
+func rightBorderArea(c *Chunk) int {
+	area := 0
+	for i := range SECTION_COUNT {
+		mesh := c.SectionMesh(i).Solid
+		for j := 0; j < len(mesh); j += QUAD_VERTICES * VERTEX_SIZE {
+			if int(mesh[j]>>24) != CHUNK_WIDTH*modelUnits || int(mesh[j]&0xF) != faceRight {
+				continue
+			}
+			minY, maxY, minZ, maxZ := math.MaxInt, 0, math.MaxInt, 0
+			for k := j; k < j+QUAD_VERTICES*VERTEX_SIZE; k += VERTEX_SIZE {
+				y, z := int(mesh[k]>>12&0xFFF), int(mesh[k]>>4&0xFF)
+				minY, maxY, minZ, maxZ = min(minY, y), max(maxY, y), min(minZ, z), max(maxZ, z)
+			}
+			area += (maxY - minY) * (maxZ - minZ) / (modelUnits * modelUnits)
+		}
+	}
+	return area
+}
+
+func TestChunkBorderSeams(t *testing.T) {
+	l, m := newTestLevel(1)
+	a := addTestChunk(l, m, utils.IntVector2{X: 0, Y: 0})
+	b := addTestChunk(l, m, utils.IntVector2{X: 1, Y: 0})
+
+	var floor, empty chunkBlocks
+	for x := range CHUNK_WIDTH {
+		for z := range CHUNK_WIDTH {
+			floor[x][-MIN_Y][z] = STONE
+		}
+	}
+	a.setBlocks(a.getCoordinates(), STATUS_FULL, &floor, false)
+	a.generateMesh(l)
+	if n := rightBorderArea(a); n != 0 {
+		t.Errorf("%d faces are drawn towards a chunk that isn't generated", n)
+	}
+
+	// the neighbour finishing its generation remeshes the chunk
+	b.setBlocks(b.getCoordinates(), STATUS_FULL, &empty, false)
+	a.generateMesh(l)
+	if n := rightBorderArea(a); n != CHUNK_WIDTH {
+		t.Errorf("%d faces are drawn towards an empty neighbour, want %d", n, CHUNK_WIDTH)
+	}
+
+	// so does an edit on its border
+	b.setBlock(utils.IntVector3{X: 0, Y: 0, Z: 7}, STONE)
+	a.generateMesh(l)
+	if n := rightBorderArea(a); n != CHUNK_WIDTH-1 {
+		t.Errorf("%d faces are drawn after a block was placed against the border, want %d", n, CHUNK_WIDTH-1)
+	}
+	b.setBlock(utils.IntVector3{X: 0, Y: 0, Z: 7}, AIR)
+	a.generateMesh(l)
+	if n := rightBorderArea(a); n != CHUNK_WIDTH {
+		t.Errorf("%d faces are drawn after the block against the border was broken, want %d", n, CHUNK_WIDTH)
+	}
+}
+
+// sixVertexFaces are the triangles the faces of a block were drawn with before quads were indexed
+var sixVertexFaces = [6][6]utils.IntVector3{
+	faceTop:    {{X: 0, Y: 1, Z: 0}, {X: 1, Y: 1, Z: 1}, {X: 1, Y: 1, Z: 0}, {X: 0, Y: 1, Z: 0}, {X: 0, Y: 1, Z: 1}, {X: 1, Y: 1, Z: 1}},
+	faceBottom: {{X: 0, Y: 0, Z: 0}, {X: 1, Y: 0, Z: 0}, {X: 1, Y: 0, Z: 1}, {X: 1, Y: 0, Z: 1}, {X: 0, Y: 0, Z: 1}, {X: 0, Y: 0, Z: 0}},
+	faceLeft:   {{X: 0, Y: 0, Z: 0}, {X: 0, Y: 0, Z: 1}, {X: 0, Y: 1, Z: 0}, {X: 0, Y: 0, Z: 1}, {X: 0, Y: 1, Z: 1}, {X: 0, Y: 1, Z: 0}},
+	faceRight:  {{X: 1, Y: 0, Z: 0}, {X: 1, Y: 1, Z: 0}, {X: 1, Y: 0, Z: 1}, {X: 1, Y: 0, Z: 1}, {X: 1, Y: 1, Z: 0}, {X: 1, Y: 1, Z: 1}},
+	faceFront:  {{X: 0, Y: 0, Z: 1}, {X: 1, Y: 0, Z: 1}, {X: 1, Y: 1, Z: 1}, {X: 0, Y: 0, Z: 1}, {X: 1, Y: 1, Z: 1}, {X: 0, Y: 1, Z: 1}},
+	faceBack:   {{X: 0, Y: 0, Z: 0}, {X: 1, Y: 1, Z: 0}, {X: 1, Y: 0, Z: 0}, {X: 0, Y: 0, Z: 0}, {X: 0, Y: 1, Z: 0}, {X: 1, Y: 1, Z: 0}},
+}
+
+// triangle returns the corners starting from the smallest one, keeping their winding
+func triangle(a, b, c utils.IntVector3) [3]utils.IntVector3 {
+	t := [3]utils.IntVector3{a, b, c}
+	less := func(p, q utils.IntVector3) bool {
+		return p.X < q.X || p.X == q.X && (p.Y < q.Y || p.Y == q.Y && p.Z < q.Z)
+	}
+	for !less(t[0], t[1]) || !less(t[0], t[2]) {
+		t = [3]utils.IntVector3{t[1], t[2], t[0]}
+	}
+	return t
+}
+
+func compareTriangles(a, b [3]utils.IntVector3) int {
+	flatten := func(t [3]utils.IntVector3) []int {
+		return []int{t[0].X, t[0].Y, t[0].Z, t[1].X, t[1].Y, t[1].Z, t[2].X, t[2].Y, t[2].Z}
+	}
+	return slices.Compare(flatten(a), flatten(b))
+}
+
+func TestQuadIndicesReproduceTriangles(t *testing.T) {
+	mesh := STONE.mesh(0, 0, 0, blockSurroundings{})
+	indices := QuadIndices(Quads(mesh))
+
+	var got, want [6][][3]utils.IntVector3
+	corner := func(i uint32) (utils.IntVector3, int) {
+		word := mesh[i*VERTEX_SIZE]
+		return utils.IntVector3{X: int(word >> 24), Y: int(word >> 12 & 0xFFF), Z: int(word >> 4 & 0xFF)}, int(word & 0xF)
+	}
+	for i := 0; i < len(indices); i += 3 {
+		a, face := corner(indices[i])
+		b, _ := corner(indices[i+1])
+		c, _ := corner(indices[i+2])
+		got[face] = append(got[face], triangle(a, b, c))
+	}
+	scale := func(p utils.IntVector3) utils.IntVector3 {
+		return utils.IntVector3{X: p.X * modelUnits, Y: p.Y * modelUnits, Z: p.Z * modelUnits}
+	}
+	for face, corners := range sixVertexFaces {
+		for i := 0; i < len(corners); i += 3 {
+			want[face] = append(want[face], triangle(scale(corners[i]), scale(corners[i+1]), scale(corners[i+2])))
+		}
+	}
+
+	for face := range got {
+		slices.SortFunc(got[face], compareTriangles)
+		slices.SortFunc(want[face], compareTriangles)
+		if !slices.Equal(got[face], want[face]) {
+			t.Errorf("face %d is drawn with the triangles %v, want %v", face, got[face], want[face])
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package level
+
+import (
+	"math/rand"
+	"slices"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+// feature is a decoration built on top of the terrain, it can spill into the neighbouring chunks
+type feature interface {
+	// place builds the feature on top of the ground block at level coordinates x, y, z
+	place(w *featureWriter, rng *rand.Rand, x, y, z int)
+}
+
+type featurePlacement struct {
+	feature feature
+	chance  float32   // probability to place the feature on a column
+	ground  []BlockId // blocks the feature can be placed on
+	margin  int       // distance to the chunk borders, features made of fixed blocks must stay in their chunk
+}
+
+/*
+featureWriter collects the blocks placed by the features of a chunk.
+Blocks inside the chunk are merged right away, the others are kept for the neighbouring chunks.
+Blocks of priorityFixed are dropped outside of the chunk, so that features never depend on the terrain of a neighbour.
+*/
+type featureWriter struct {
+	coordinates utils.IntVector2
+	blocks      *chunkBlocks
+	outside     map[utils.IntVector2][]blockWrite
+}
+
+func newFeatureWriter(coordinates utils.IntVector2, blocks *chunkBlocks) *featureWriter {
+	return &featureWriter{
+		coordinates: coordinates,
+		blocks:      blocks,
+		outside:     make(map[utils.IntVector2][]blockWrite),
+	}
+}
+
+// set places b at level coordinates x, y, z
+func (w *featureWriter) set(x, y, z int, b BlockId) {
+	if y < MIN_Y || y >= MAX_Y {
+		return
+	}
+
+	chunk := utils.IntVector2{X: floorDiv(x, CHUNK_WIDTH), Y: floorDiv(z, CHUNK_WIDTH)}
+	position := utils.IntVector3{X: utils.Mod(x, CHUNK_WIDTH), Y: y, Z: utils.Mod(z, CHUNK_WIDTH)}
+	if chunk == w.coordinates {
+		w.blocks[position.X][y-MIN_Y][position.Z] = mergeFeatureBlock(w.blocks[position.X][y-MIN_Y][position.Z], b)
+	} else if featurePriority(b) != priorityFixed {
+		w.outside[chunk] = append(w.outside[chunk], blockWrite{position, b})
+	}
+}
+
+// placeFeatures tries every feature of the biome of each column, at most one feature is placed per column
+func placeFeatures(w *featureWriter, rng *rand.Rand, biomeAt func(x, z int) *biome) {
+	for i := range CHUNK_WIDTH {
+		for j := range CHUNK_WIDTH {
+			x := i + w.coordinates.X*CHUNK_WIDTH
+			z := j + w.coordinates.Y*CHUNK_WIDTH
+
+			y := MAX_Y - 1
+			for y > MIN_Y && w.blocks[i][y-MIN_Y][j] == AIR {
+				y--
+			}
+			ground := w.blocks[i][y-MIN_Y][j]
+
+			for _, p := range biomeAt(x, z).features {
+				// always draw, so that the random sequence doesn't depend on the terrain
+				if rng.Float32() >= p.chance {
+					continue
+				}
+				if i < p.margin || j < p.margin || i >= CHUNK_WIDTH-p.margin || j >= CHUNK_WIDTH-p.margin ||
+					!slices.Contains(p.ground, ground) {
+					continue
+				}
+
+				p.feature.place(w, rng, x, y, z)
+				break
+			}
+		}
+	}
+}
+
+// oakTree is a trunk topped by a round crown of leaves
+type oakTree struct {
+	minHeight int
+	maxHeight int
+}
+
+func (t oakTree) place(w *featureWriter, rng *rand.Rand, x, y, z int) {
+	height := t.minHeight + rng.Intn(t.maxHeight-t.minHeight+1)
+	top := y + height
+
+	for dy := -2; dy <= 1; dy++ {
+		radius := 2
+		if dy >= 0 {
+			radius = 1
+		}
+		for dx := -radius; dx <= radius; dx++ {
+			for dz := -radius; dz <= radius; dz++ {
+				corner := (dx == -radius || dx == radius) && (dz == -radius || dz == radius)
+				// random corners on the wide layers, no corners on the top layer
+				if corner && (dy == 1 || rng.Intn(2) == 0) {
+					continue
+				}
+				w.set(x+dx, top+dy, z+dz, LEAVES)
+			}
+		}
+	}
+
+	for k := y + 1; k <= top; k++ {
+		w.set(x, k, z, LOG)
+	}
+}
+
+// spruceTree is a trunk surrounded by a cone of leaves
+type spruceTree struct {
+	minHeight int
+	maxHeight int
+}
+
+func (t spruceTree) place(w *featureWriter, rng *rand.Rand, x, y, z int) {
+	height := t.minHeight + rng.Intn(t.maxHeight-t.minHeight+1)
+	top := y + height
+
+	w.set(x, top+1, z, LEAVES)
+	for k := y + 3; k <= top; k++ {
+		// layers alternate between wide and narrow, getting wider downwards
+		d := top - k
+		radius := min((d+2)/2, 3)
+		if d%2 == 1 {
+			radius = max(radius-1, 1)
+		}
+		for dx := -radius; dx <= radius; dx++ {
+			for dz := -radius; dz <= radius; dz++ {
+				if radius > 1 && (dx == -radius || dx == radius) && (dz == -radius || dz == radius) {
+					continue
+				}
+				w.set(x+dx, k, z+dz, LEAVES)
+			}
+		}
+	}
+
+	for k := y + 1; k <= top; k++ {
+		w.set(x, k, z, LOG)
+	}
+}
+
+// columnFeature is a pile of a single block, like a cactus
+type columnFeature struct {
+	block     BlockId
+	maxHeight int
+}
+
+func (c columnFeature) place(w *featureWriter, rng *rand.Rand, x, y, z int) {
+	height := 1 + rng.Intn(c.maxHeight)
+	for k := y + 1; k <= y+height; k++ {
+		w.set(x, k, z, c.block)
+	}
+}
+
+// boulder is a rough sphere of block half buried in the ground
+type boulder struct {
+	block     BlockId
+	maxRadius int
+}
+
+func (b boulder) place(w *featureWriter, rng *rand.Rand, x, y, z int) {
+	radius := 1 + rng.Intn(b.maxRadius)
+	for dx := -radius; dx <= radius; dx++ {
+		for dy := -radius; dy <= radius; dy++ {
+			for dz := -radius; dz <= radius; dz++ {
+				if dx*dx+dy*dy+dz*dz <= radius*radius+rng.Intn(2) {
+					w.set(x+dx, y+dy, z+dz, b.block)
+				}
+			}
+		}
+	}
+}
+
+// plant is a single block placed on the ground
+type plant struct {
+	block BlockId
+}
+
+func (p plant) place(w *featureWriter, rng *rand.Rand, x, y, z int) {
+	w.set(x, y+1, z, p.block)
+}
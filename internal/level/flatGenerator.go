@@ -0,0 +1,69 @@
+package level
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+const DEFAULT_FLAT_PRESET = "stone,2*dirt,grass"
+
+// flatGenerator generates the same layers of blocks in every chunk
+type flatGenerator struct {
+	layers []BlockId // from y = 0 upwards
+}
+
+/*
+NewFlatGenerator creates a superflat generator from a preset.
+A preset is a comma separated list of layers from the bottom of the world upwards,
+each layer is a block name optionally prefixed by a thickness, i.e. "stone,2*dirt,grass".
+The blocks must be loaded before calling NewFlatGenerator.
+*/
+func NewFlatGenerator(preset string) (Generator, error) {
+	if preset == "" {
+		preset = DEFAULT_FLAT_PRESET
+	}
+
+	layers := make([]BlockId, 0)
+	for _, layer := range strings.Split(preset, ",") {
+		thickness := 1
+		name := strings.TrimSpace(layer)
+		if before, after, found := strings.Cut(name, "*"); found {
+			var err error
+			thickness, err = strconv.Atoi(strings.TrimSpace(before))
+			if err != nil || thickness < 1 {
+				return nil, fmt.Errorf("invalid thickness in layer \"%s\"", layer)
+			}
+			name = strings.TrimSpace(after)
+		}
+
+		id, ok := blockByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown block \"%s\"", name)
+		}
+
+		for range thickness {
+			layers = append(layers, id)
+		}
+	}
+
+	if len(layers) > MAX_Y {
+		return nil, fmt.Errorf("preset has %d layers, the world is only %d blocks high above y = 0", len(layers), MAX_Y)
+	}
+
+	return &flatGenerator{layers}, nil
+}
+
+func (g *flatGenerator) GenerateTerrain(coordinates utils.IntVector2) *chunkBlocks {
+	var blocks chunkBlocks
+	for i := range CHUNK_WIDTH {
+		for j := range CHUNK_WIDTH {
+			for y, id := range g.layers {
+				blocks[i][y-MIN_Y][j] = id
+			}
+		}
+	}
+	return &blocks
+}
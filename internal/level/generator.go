@@ -0,0 +1,63 @@
+package level
+
+import (
+	"fmt"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+const (
+	GENERATOR_NOISE = "noise"
+	GENERATOR_FLAT  = "flat"
+	GENERATOR_VOID  = "void"
+)
+
+// Generator creates the content of new chunks, one generation stage at a time, see generationStages.
+// GenerateTerrain must only depend on the coordinates and the settings of the generator,
+// it is called concurrently from the world generator workers.
+type Generator interface {
+	GenerateTerrain(coordinates utils.IntVector2) *chunkBlocks
+}
+
+// carver is implemented by generators digging caves or ravines out of the terrain returned by GenerateTerrain
+type carver interface {
+	carve(coordinates utils.IntVector2, blocks *chunkBlocks)
+}
+
+/*
+featurePlacer is implemented by generators placing features that can spill into the neighbouring chunks.
+placeFeatures adds the features to the carved blocks and returns the blocks placed in other chunks,
+it must only depend on the coordinates and those blocks.
+*/
+type featurePlacer interface {
+	placeFeatures(coordinates utils.IntVector2, blocks *chunkBlocks) map[utils.IntVector2][]blockWrite
+}
+
+// NewGenerator returns the generator described by the world information
+func NewGenerator(info WorldInfo) (Generator, error) {
+	switch info.Generator {
+	case GENERATOR_NOISE, "":
+		return NewNoiseGenerator(info.Seed), nil
+	case GENERATOR_FLAT:
+		generator, err := NewFlatGenerator(info.Preset)
+		if err != nil {
+			return nil, fmt.Errorf("NewFlatGenerator(): %w", err)
+		}
+		return generator, nil
+	case GENERATOR_VOID:
+		return NewVoidGenerator(), nil
+	default:
+		return nil, fmt.Errorf("unknown generator \"%s\"", info.Generator)
+	}
+}
+
+// voidGenerator generates chunks filled with air
+type voidGenerator struct{}
+
+func NewVoidGenerator() Generator {
+	return voidGenerator{}
+}
+
+func (voidGenerator) GenerateTerrain(coordinates utils.IntVector2) *chunkBlocks {
+	return &chunkBlocks{}
+}
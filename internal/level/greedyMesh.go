@@ -0,0 +1,198 @@
+package level
+
+import "github.com/vparent05/minecraft_go/internal/utils"
+
+// planeSize is the number of cells of a side of the planes of a section
+const planeSize = max(CHUNK_WIDTH, SECTION_HEIGHT)
+
+// faceAxes are the axes of the planes of the faces: the 2 in the plane, then the normal, x = 0, y = 1, z = 2
+var faceAxes = [6][3]int{
+	faceTop:    {0, 2, 1},
+	faceBottom: {0, 2, 1},
+	faceLeft:   {2, 1, 0},
+	faceRight:  {2, 1, 0},
+	faceFront:  {0, 1, 2},
+	faceBack:   {0, 1, 2},
+}
+
+func component(p utils.IntVector3, axis int) int {
+	switch axis {
+	case 0:
+		return p.X
+	case 1:
+		return p.Y
+	default:
+		return p.Z
+	}
+}
+
+func setComponent(p *utils.IntVector3, axis, value int) {
+	switch axis {
+	case 0:
+		p.X = value
+	case 1:
+		p.Y = value
+	default:
+		p.Z = value
+	}
+}
+
+// mergePlane is a plane of a section holding faces that can be merged
+type mergePlane struct {
+	face        int
+	depth       int // position of the plane along the normal of face, in sixteenths from the origin of the section
+	transparent bool
+}
+
+// mergeFace is a quad covering a whole side of a block, with its vertices relative to the block
+type mergeFace struct {
+	texture   int
+	vertices  [QUAD_VERTICES]modelVertex
+	light     quadLight
+	occlusion quadOcclusion
+}
+
+/*
+greedyMesher merges the faces of a section lying in the same plane into larger quads,
+as long as they show the same texture the same way under the same light and occlusion, the texture then repeats across the quad.
+The faces that don't cover a whole side of their block are left to the caller.
+*/
+type greedyMesher struct {
+	faces   []mergeFace
+	indices map[mergeFace]int32
+	planes  map[mergePlane]*[planeSize][planeSize]int32 // index + 1 of the face of every cell in faces, 0 for none
+	order   []mergePlane                                // planes in the order they were found
+}
+
+func newGreedyMesher() *greedyMesher {
+	return &greedyMesher{
+		indices: make(map[mergeFace]int32),
+		planes:  make(map[mergePlane]*[planeSize][planeSize]int32),
+	}
+}
+
+// add keeps q, a quad of the block at x, y, z in the section drawn with light l and occlusion, to be merged, it returns false if it can't be
+func (m *greedyMesher) add(q modelQuad, x, y, z int, transparent bool, l quadLight, occlusion quadOcclusion) bool {
+	if q.face >= faceNone {
+		return false
+	}
+	axes := faceAxes[q.face]
+	depth := component(q.vertices[0].position, axes[2])
+	for _, vertex := range q.vertices {
+		a, b := component(vertex.position, axes[0]), component(vertex.position, axes[1])
+		if component(vertex.position, axes[2]) != depth || a != 0 && a != modelUnits || b != 0 && b != modelUnits {
+			return false
+		}
+	}
+
+	block := utils.IntVector3{X: x, Y: y, Z: z}
+	plane := mergePlane{q.face, component(block, axes[2])*modelUnits + depth, transparent}
+	cells, ok := m.planes[plane]
+	if !ok {
+		cells = new([planeSize][planeSize]int32)
+		m.planes[plane] = cells
+		m.order = append(m.order, plane)
+	}
+	cell := &cells[component(block, axes[0])][component(block, axes[1])]
+	if *cell != 0 {
+		return false
+	}
+
+	f := mergeFace{BLOCK_TEXTURE_LAYERS[q.texture], q.vertices, l, occlusion}
+	index, ok := m.indices[f]
+	if !ok {
+		m.faces = append(m.faces, f)
+		index = int32(len(m.faces))
+		m.indices[f] = index
+	}
+	*cell = index
+	return true
+}
+
+// appendTo appends the merged quads to mesh, growing each one along the second axis of its plane then along the first
+func (m *greedyMesher) appendTo(mesh *ChunkMesh) {
+	for _, plane := range m.order {
+		cells := m.planes[plane]
+		for a := range planeSize {
+			for b := range planeSize {
+				index := cells[a][b]
+				if index == 0 {
+					continue
+				}
+
+				h := 1
+				for b+h < planeSize && cells[a][b+h] == index {
+					h++
+				}
+				w := 1
+			grow:
+				for a+w < planeSize {
+					for j := b; j < b+h; j++ {
+						if cells[a+w][j] != index {
+							break grow
+						}
+					}
+					w++
+				}
+
+				for i := a; i < a+w; i++ {
+					for j := b; j < b+h; j++ {
+						cells[i][j] = 0
+					}
+				}
+				if plane.transparent {
+					mesh.Transparent = m.faces[index-1].appendMerged(mesh.Transparent, plane, a, b, w, h)
+				} else {
+					mesh.Solid = m.faces[index-1].appendMerged(mesh.Solid, plane, a, b, w, h)
+				}
+			}
+		}
+	}
+}
+
+// slopes returns how many sixteenths of texture u and v move by per sixteenth of block along the axes a and b of the plane
+func (f mergeFace) slopes(axes [3]int) (uA, uB, vA, vB int) {
+	for _, v0 := range f.vertices {
+		for _, v1 := range f.vertices {
+			da := component(v1.position, axes[0]) - component(v0.position, axes[0])
+			db := component(v1.position, axes[1]) - component(v0.position, axes[1])
+			if da != 0 && db == 0 {
+				uA, vA = (v1.u-v0.u)/da, (v1.v-v0.v)/da
+			}
+			if db != 0 && da == 0 {
+				uB, vB = (v1.u-v0.u)/db, (v1.v-v0.v)/db
+			}
+		}
+	}
+	return uA, uB, vA, vB
+}
+
+// appendMerged appends the quad covering w by h faces f from the cell a, b of plane
+func (f mergeFace) appendMerged(mesh []uint32, plane mergePlane, a, b, w, h int) []uint32 {
+	axes := faceAxes[plane.face]
+	uA, uB, vA, vB := f.slopes(axes)
+
+	var positions [QUAD_VERTICES]utils.IntVector3
+	var us, vs [QUAD_VERTICES]int
+	minU, minV := 0, 0
+	for i, vertex := range f.vertices {
+		// the far sides of the face move to the far sides of the quad
+		pa, pb := component(vertex.position, axes[0]), component(vertex.position, axes[1])
+		da, db := pa/modelUnits*(w-1)*modelUnits, pb/modelUnits*(h-1)*modelUnits
+		setComponent(&positions[i], axes[0], a*modelUnits+pa+da)
+		setComponent(&positions[i], axes[1], b*modelUnits+pb+db)
+		setComponent(&positions[i], axes[2], plane.depth)
+		us[i], vs[i] = vertex.u+uA*da+uB*db, vertex.v+vA*da+vB*db
+		minU, minV = min(minU, us[i]), min(minV, vs[i])
+	}
+
+	// the texture repeats every modelUnits, whole repetitions keep the coordinates positive
+	shiftU := (-minU + modelUnits - 1) / modelUnits * modelUnits
+	shiftV := (-minV + modelUnits - 1) / modelUnits * modelUnits
+	first := f.occlusion.firstVertex()
+	for i := range positions {
+		j := (first + i) % QUAD_VERTICES
+		mesh = appendVertex(mesh, positions[j], plane.face, f.texture, us[j]+shiftU, vs[j]+shiftV, f.light[j], f.occlusion[j])
+	}
+	return mesh
+}
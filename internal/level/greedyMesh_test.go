@@ -0,0 +1,112 @@
+package level
+
+import (
+	"testing"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+func TestGreedyMeshMergesFloor(t *testing.T) {
+	l, m := newTestLevel(1)
+	c := addTestChunk(l, m, utils.IntVector2{X: 0, Y: 0})
+	var floor chunkBlocks
+	for x := range CHUNK_WIDTH {
+		for z := range CHUNK_WIDTH {
+			floor[x][-MIN_Y][z] = STONE
+		}
+	}
+	c.setBlocks(c.getCoordinates(), STATUS_FULL, &floor, false)
+	c.generateMesh(l)
+
+	// the sides face chunks that aren't loaded, only the top and the bottom are drawn
+	section, _ := sectionOf(0)
+	mesh := c.SectionMesh(section).Solid
+	if quads := Quads(mesh); quads != 2 {
+		t.Fatalf("the floor is drawn with %d quads, want 2", quads)
+	}
+	// the texture repeats once per block
+	maxU := 0
+	for i := 0; i < len(mesh); i += VERTEX_SIZE {
+		maxU = max(maxU, int(mesh[i+1]>>9&0x1FF))
+	}
+	if maxU != CHUNK_WIDTH*modelUnits {
+		t.Errorf("the texture coordinates of the floor go up to %d, want %d", maxU, CHUNK_WIDTH*modelUnits)
+	}
+
+	// a different block splits the top in quads that still cover it exactly
+	c.setBlock(utils.IntVector3{X: 7, Y: 0, Z: 7}, DIRT)
+	c.generateMesh(l)
+	mesh = c.SectionMesh(section).Solid
+	area := 0
+	for i := 0; i < len(mesh); i += QUAD_VERTICES * VERTEX_SIZE {
+		if int(mesh[i]&0xF) != faceTop {
+			continue
+		}
+		minX, maxX, minZ, maxZ := planeSize*modelUnits, 0, planeSize*modelUnits, 0
+		for j := i; j < i+QUAD_VERTICES*VERTEX_SIZE; j += VERTEX_SIZE {
+			x, z := int(mesh[j]>>24), int(mesh[j]>>4&0xFF)
+			minX, maxX, minZ, maxZ = min(minX, x), max(maxX, x), min(minZ, z), max(maxZ, z)
+		}
+		area += (maxX - minX) * (maxZ - minZ) / (modelUnits * modelUnits)
+	}
+	if area != CHUNK_WIDTH*CHUNK_WIDTH {
+		t.Errorf("the top of the floor covers %d blocks, want %d", area, CHUNK_WIDTH*CHUNK_WIDTH)
+	}
+}
+
+// generatedLevel returns a level of 3 by 3 generated chunks and its center chunk
+func generatedLevel(b *testing.B) (*Level, *Chunk) {
+	b.Helper()
+	l, m := newTestLevel(1)
+	area := GenerateArea(NewNoiseGenerator(42), utils.IntVector2{X: 2, Y: -3}, utils.IntVector2{X: 4, Y: -1})
+	var center *Chunk
+	for x := 2; x <= 4; x++ {
+		for z := -3; z <= -1; z++ {
+			coordinates := utils.IntVector2{X: x, Y: z}
+			c := addTestChunk(l, m, coordinates)
+			c.setBlocks(coordinates, STATUS_FULL, area.chunk(coordinates).dense(), false)
+			if x == 3 && z == -2 {
+				center = c
+			}
+		}
+	}
+	return l, center
+}
+
+var benchmarkMesh ChunkMesh
+
+// BenchmarkChunkMesh compares meshing a generated chunk one quad per face, as before, and with greedy meshing
+func BenchmarkChunkMesh(b *testing.B) {
+	l, c := generatedLevel(b)
+	snap := c.snapshot()
+	b.Run("faces", func(b *testing.B) {
+		vertices := 0
+		for b.Loop() {
+			vertices = 0
+			for i := range SECTION_COUNT {
+				benchmarkMesh = ChunkMesh{}
+				bottom := SectionBottom(i)
+				for pos, block := range snap.iter(i) {
+					if block == AIR {
+						continue
+					}
+					s := block.surroundings(snap.neighbours(l, pos.X, bottom+pos.Y, pos.Z))
+					benchmarkMesh.Solid = append(benchmarkMesh.Solid, block.mesh(pos.X, pos.Y, pos.Z, s)...)
+				}
+				vertices += len(benchmarkMesh.Solid) / VERTEX_SIZE
+			}
+		}
+		b.ReportMetric(float64(vertices), "vertices/chunk")
+	})
+	b.Run("greedy", func(b *testing.B) {
+		vertices := 0
+		for b.Loop() {
+			vertices = 0
+			for i := range SECTION_COUNT {
+				benchmarkMesh = snap.meshSection(l, i)
+				vertices += (len(benchmarkMesh.Solid) + len(benchmarkMesh.Transparent)) / VERTEX_SIZE
+			}
+		}
+		b.ReportMetric(float64(vertices), "vertices/chunk")
+	})
+}
@@ -1,6 +1,8 @@
 package level
 
 import (
+	"errors"
+	"fmt"
 	"iter"
 	"math"
 
@@ -21,6 +23,15 @@ func (b *blockPosition) Set(value BlockId) {
 	b.c.setBlock(b.i, value)
 }
 
+// Position returns the level coordinates of the block
+func (b *blockPosition) Position() utils.IntVector3 {
+	if b.c == nil {
+		return b.i
+	}
+	coordinates := b.c.getCoordinates()
+	return utils.IntVector3{X: coordinates.X*CHUNK_WIDTH + b.i.X, Y: b.i.Y, Z: coordinates.Y*CHUNK_WIDTH + b.i.Z}
+}
+
 func (b *blockPosition) Get() (BlockId, bool) {
 	if b.c == nil {
 		return 0, false
@@ -38,12 +49,21 @@ type Level struct {
 	observerCache LevelObserver
 	chunks        [][]*Chunk
 	generateOrder [][2]int
+	storage       *WorldStorage
+	generator     Generator
+	pending       *pendingWrites
+	light         lightEngine
 }
 
-func NewLevel(observer *atomicx.Value[LevelObserver]) *Level {
+// NewLevel creates a level whose new chunks come from generator and that is persisted in storage,
+// storage may be nil for a level that is never saved
+func NewLevel(observer *atomicx.Value[LevelObserver], storage *WorldStorage, generator Generator) *Level {
 	return &Level{
 		observer:      observer,
 		observerCache: observer.Load(),
+		storage:       storage,
+		generator:     generator,
+		pending:       newPendingWrites(storage),
 	}
 }
 
@@ -67,6 +87,21 @@ func (l *Level) getChunk(chunkCoordinates utils.IntVector2) *Chunk {
 	return l.chunks[i][j]
 }
 
+// remeshBorders remeshes borders[i] of the chunk at borderOffsets[i] from coordinates, for the ones that are loaded
+func (l *Level) remeshBorders(coordinates utils.IntVector2, borders borderSections) {
+	if len(l.chunks) == 0 {
+		return
+	}
+	for i, offset := range borderOffsets {
+		if borders[i] == 0 {
+			continue
+		}
+		if c := l.getChunk(coordinates.Add(offset)); c != nil {
+			c.invalidateMeshes(coordinates.Add(offset), borders[i])
+		}
+	}
+}
+
 func (l *Level) setChunk(chunkCoordinates utils.IntVector2, value *Chunk) {
 	i, j := l.chunkIndex(chunkCoordinates)
 	value.Slot = i*len(l.chunks) + j
@@ -93,14 +128,15 @@ func (l *Level) Chunks() iter.Seq2[utils.IntVector2, *Chunk] {
 
 func (l *Level) getBlockPosition(position mgl32.Vec3) *blockPosition {
 	blockX := utils.Mod(int(position.X()), CHUNK_WIDTH)
+	blockY := int(math.Floor(float64(position.Y())))
 	blockZ := utils.Mod(int(position.Z()), CHUNK_WIDTH)
 
 	chunk := l.getChunk(LevelToChunkCoords(position))
-	if chunk == nil {
+	if chunk == nil || blockY < MIN_Y || blockY >= MAX_Y {
 		return &blockPosition{nil, utils.IntVector3{}}
 	}
 
-	return &blockPosition{chunk, utils.IntVector3{X: blockX, Y: utils.Mod(int(position.Y()), CHUNK_HEIGHT), Z: blockZ}}
+	return &blockPosition{chunk, utils.IntVector3{X: blockX, Y: blockY, Z: blockZ}}
 }
 
 func t(from, offset, orientation float32) float32 {
@@ -153,9 +189,9 @@ func (l *Level) CastRay(position mgl32.Vec3, orientation mgl32.Vec3, length floa
 			}
 		}
 
-		// if targeted block exists and isn't air, return targeted block
+		// if targeted block exists and the ray hits its shape, return targeted block
 		targeted := l.getBlockPosition(blockPos)
-		if tBlock, ok := targeted.Get(); ok && tBlock != AIR {
+		if tBlock, ok := targeted.Get(); ok && tBlock != AIR && l.rayHits(position, orientation, length, blockPos) {
 
 			// if front block exists, return targeted block and front block
 			front := l.getBlockPosition(previousBlockPos)
@@ -169,6 +205,115 @@ func (l *Level) CastRay(position mgl32.Vec3, orientation mgl32.Vec3, length floa
 	return nil, nil
 }
 
+// rayHits returns true if the ray hits the shape of the block at blockPos before length
+func (l *Level) rayHits(position mgl32.Vec3, orientation mgl32.Vec3, length float32, blockPos mgl32.Vec3) bool {
+	for _, box := range l.boxesAt(utils.IntVector3{X: int(blockPos.X()), Y: int(blockPos.Y()), Z: int(blockPos.Z())}, false) {
+		if t, ok := box.IntersectRay(position, orientation); ok && t*orientation.Len() <= length {
+			return true
+		}
+	}
+	return false
+}
+
+// blockAt returns the block at level coordinates p, AIR above and below the level, ok is false if its chunk isn't loaded
+func (l *Level) blockAt(p utils.IntVector3) (BlockId, bool) {
+	if p.Y < MIN_Y || p.Y >= MAX_Y {
+		return AIR, true
+	}
+	if len(l.chunks) == 0 {
+		return AIR, false
+	}
+
+	coordinates := utils.IntVector2{X: floorDiv(p.X, CHUNK_WIDTH), Y: floorDiv(p.Z, CHUNK_WIDTH)}
+	chunk := l.getChunk(coordinates)
+	if chunk == nil || chunk.statusAt(coordinates) != STATUS_FULL {
+		return AIR, false
+	}
+	return chunk.getBlock(utils.IntVector3{X: utils.Mod(p.X, CHUNK_WIDTH), Y: p.Y, Z: utils.Mod(p.Z, CHUNK_WIDTH)}), true
+}
+
+// lightAt returns the light of the block at level coordinates p, the sky light above the level, ok is false if its chunk isn't loaded
+func (l *Level) lightAt(p utils.IntVector3) (light, bool) {
+	if p.Y >= MAX_Y {
+		return skyLight, true
+	}
+	if p.Y < MIN_Y {
+		return 0, true
+	}
+	if len(l.chunks) == 0 {
+		return 0, false
+	}
+
+	coordinates, local := chunkPosition(p)
+	chunk := l.getChunk(coordinates)
+	if chunk == nil || chunk.statusAt(coordinates) != STATUS_FULL {
+		return 0, false
+	}
+	return chunk.getLight(local), true
+}
+
+// boxesAt returns the boxes of the block at level coordinates p, only the ones entities collide with if solid is true
+func (l *Level) boxesAt(p utils.IntVector3, solid bool) []Box {
+	b, ok := l.blockAt(p)
+	if !ok || b == AIR {
+		return nil
+	}
+
+	var neighbours [6]BlockId
+	var loaded [6]bool
+	for face, normal := range faceNormals {
+		neighbours[face], loaded[face] = l.blockAt(p.Add(normal))
+	}
+
+	boxes := b.boxes(b.surroundings(neighbours, loaded), solid)
+	for i := range boxes {
+		boxes[i] = boxes[i].Offset(mgl32.Vec3{float32(p.X), float32(p.Y), float32(p.Z)})
+	}
+	return boxes
+}
+
+/*
+Clip returns how much of displacement box can move before colliding with the blocks of the level, one axis after the other, y first.
+Blocks box already overlaps don't stop it, so an entity stuck in a block can get out.
+*/
+func (l *Level) Clip(box Box, displacement mgl32.Vec3) mgl32.Vec3 {
+	swept := box.expand(displacement)
+	var obstacles []Box
+	for x := int(math.Floor(float64(swept.Min.X()))); x <= int(math.Floor(float64(swept.Max.X()))); x++ {
+		for y := int(math.Floor(float64(swept.Min.Y()))); y <= int(math.Floor(float64(swept.Max.Y()))); y++ {
+			for z := int(math.Floor(float64(swept.Min.Z()))); z <= int(math.Floor(float64(swept.Max.Z()))); z++ {
+				for _, obstacle := range l.boxesAt(utils.IntVector3{X: x, Y: y, Z: z}, true) {
+					if !obstacle.Intersects(box) {
+						obstacles = append(obstacles, obstacle)
+					}
+				}
+			}
+		}
+	}
+
+	for _, axis := range [3]int{1, 0, 2} {
+		d := displacement[axis]
+		for _, obstacle := range obstacles {
+			// only the obstacles in the way along the axis
+			other := box
+			other.Min[axis], other.Max[axis] = obstacle.Min[axis], obstacle.Max[axis]
+			if !other.Intersects(obstacle) {
+				continue
+			}
+
+			if d > 0 && obstacle.Min[axis] >= box.Max[axis] {
+				d = min(d, obstacle.Min[axis]-box.Max[axis])
+			} else if d < 0 && obstacle.Max[axis] <= box.Min[axis] {
+				d = max(d, obstacle.Max[axis]-box.Min[axis])
+			}
+		}
+		displacement[axis] = d
+		box.Min[axis] += d
+		box.Max[axis] += d
+	}
+	return displacement
+}
+
 func (l *Level) updateGenerateOrder() {
 	size := l.observerCache.RenderDistance*2 + 1
 	l.generateOrder = make([][2]int, 0, size*size)
@@ -188,7 +333,7 @@ func (l *Level) GenerateAround() {
 	meshBuilder := newMeshBuilder(l, l.observerCache.RenderDistance) // TODO update render distance dynamically
 	meshBuilder.start(MESH_BUILDING_WORKER_COUNT)
 
-	worldGenerator := newWorldGenerator()
+	worldGenerator := newWorldGenerator(l)
 	worldGenerator.start(WORLD_GENERATOR_WORKER_COUNT)
 
 	for {
@@ -216,13 +361,16 @@ func (l *Level) GenerateAround() {
 			pos := utils.IntVector2{X: xOffset + observerChunkCoords.X, Y: zOffset + observerChunkCoords.Y}
 			if c := l.getChunk(pos); c == nil || pos != c.coordinates {
 				if c == nil {
-					c = newChunk(meshBuilder, l.observer)
+					c = newChunk(meshBuilder)
 				}
 
 				c.clearMesh()
-				c.setCoordinates(pos)
-				worldGenerator.enqueue(c)
+				if err := c.setCoordinates(pos, l.storage); err != nil {
+					fmt.Println("Error saving chunk:", err)
+				}
+				// in place before its stages run, so its neighbours can find it
 				l.setChunk(pos, c)
+				worldGenerator.enqueue(c)
 			}
 		}
 	}
@@ -231,6 +379,26 @@ func (l *Level) GenerateAround() {
 	worldGenerator.stopWorkers()
 }
 
+// Biome returns the biome of the column at x, z, ok is false if the level's generator doesn't place biomes
+func (l *Level) Biome(x, z int) (biome BiomeId, ok bool) {
+	source, ok := l.generator.(BiomeSource)
+	if !ok {
+		return 0, false
+	}
+	return source.Biome(x, z), true
+}
+
+// Save writes every modified chunk of the level to its storage
+func (l *Level) Save() error {
+	var errs []error
+	for _, chunk := range l.Chunks() {
+		if err := chunk.save(l.storage); err != nil {
+			errs = append(errs, fmt.Errorf("save(): %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func LevelToChunkCoords(level mgl32.Vec3) utils.IntVector2 {
 	return utils.IntVector2{
 		X: int(math.Floor(float64(level.X() / CHUNK_WIDTH))),
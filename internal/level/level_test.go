@@ -0,0 +1,16 @@
+package level
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	err := LoadBlocks("../../data/blocks.json")
+	if err != nil {
+		fmt.Println("Error loading blocks:", err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
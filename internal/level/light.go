@@ -0,0 +1,239 @@
+package level
+
+import (
+	"slices"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+// MAX_LIGHT is the light of the sky and of the brightest blocks, it goes down by 1 for every block it spreads through
+const MAX_LIGHT = 15
+
+// light holds the level of every light channel of a block on 4 bits, channel c in bits 4c to 4c+3
+type light uint16
+
+// The light channels: the sky light comes down from the top of the level, the red, green and blue ones from the blocks that emit them
+const (
+	skyChannel = iota
+	redChannel
+	greenChannel
+	blueChannel
+	lightChannels
+)
+
+func (l light) channel(c int) int {
+	return int(l >> (4 * c) & 0xF)
+}
+
+func (l light) with(c, level int) light {
+	return l&^(0xF<<(4*c)) | light(level)<<(4*c)
+}
+
+// skyLight is the light of the blocks the sky shines on
+var skyLight = light(0).with(skyChannel, MAX_LIGHT)
+
+// colorLight returns the light of the red, green and blue levels of rgb
+func colorLight(rgb [3]int) light {
+	return light(0).with(redChannel, rgb[0]).with(greenChannel, rgb[1]).with(blueChannel, rgb[2])
+}
+
+// blockLights holds the light of a block and of the 26 blocks around it, the one at offset o from it at index (o.X+1)*9 + (o.Y+1)*3 + o.Z+1
+type blockLights [27]light
+
+func blockLightIndex(offset utils.IntVector3) int {
+	return (offset.X+1)*9 + (offset.Y+1)*3 + offset.Z + 1
+}
+
+func (l *blockLights) at(offset utils.IntVector3) light {
+	return l[blockLightIndex(offset)]
+}
+
+// quadLight is the light of every vertex of a quad
+type quadLight [QUAD_VERTICES]light
+
+// flatLight returns the light of a quad drawn with l at every vertex
+func flatLight(l light) quadLight {
+	return quadLight{l, l, l, l}
+}
+
+/*
+smoothLight returns the light of the vertices of q, a quad on a side of a block whose neighbours are o and lit by lights.
+Every channel of a vertex is the average of the blocks of the layer in front of the face touching it, as for the ambient occlusion,
+leaving out the opaque ones, which are dark, and the corner when both sides hide it.
+*/
+func (q modelQuad) smoothLight(o blockOccluders, lights *blockLights) quadLight {
+	var result quadLight
+	for i, vertex := range q.vertices {
+		var sums [lightChannels]int
+		count := 0
+		add := func(offset utils.IntVector3) {
+			l := lights.at(offset)
+			for c := range lightChannels {
+				sums[c] += l.channel(c)
+			}
+			count++
+		}
+
+		sideA, sideB, corner := q.cornerBlocks(vertex)
+		add(faceNormals[q.face])
+		for _, side := range [2]utils.IntVector3{sideA, sideB} {
+			if !o.has(side) {
+				add(side)
+			}
+		}
+		if !o.has(corner) && !(o.has(sideA) && o.has(sideB)) {
+			add(corner)
+		}
+
+		for c := range lightChannels {
+			result[i] = result[i].with(c, sums[c]/count)
+		}
+	}
+	return result
+}
+
+// lightSection holds the light of the blocks of a section, indexed by sectionIndex
+type lightSection [sectionVolume]light
+
+func (s *lightSection) clone() *lightSection {
+	c := *s
+	return &c
+}
+
+// chunkLight holds the light of the blocks of a chunk in sections, a section is nil while all its blocks have the light in uniform
+type chunkLight struct {
+	sharedSections[lightSection]
+	uniform [SECTION_COUNT]light
+}
+
+// get returns the light of the block at x, y, z in the chunk, y goes from MIN_Y to MAX_Y
+func (c *chunkLight) get(x, y, z int) light {
+	i, sectionY := sectionOf(y)
+	s := c.sections[i]
+	if s == nil {
+		return c.uniform[i]
+	}
+	return s[sectionIndex(x, sectionY, z)]
+}
+
+func (c *chunkLight) set(x, y, z int, l light) {
+	i, sectionY := sectionOf(y)
+	switch {
+	case c.sections[i] == nil && l == c.uniform[i]:
+		return
+	case c.sections[i] == nil:
+		s := new(lightSection)
+		for j := range s {
+			s[j] = c.uniform[i]
+		}
+		c.sections[i] = s
+	}
+	c.own(i, (*lightSection).clone)
+	c.sections[i][sectionIndex(x, sectionY, z)] = l
+}
+
+// share returns a copy of c using the same light sections, the uniform light of the others is copied with it
+func (c *chunkLight) share() chunkLight {
+	c.markShared()
+	return *c
+}
+
+// emission returns the colored light the block emits
+func (b BlockId) emission() light {
+	if b == AIR {
+		return 0
+	}
+	lights := BLOCK_TYPES[b.Type()].lights
+	if b.state() >= len(lights) {
+		return 0
+	}
+	return lights[b.state()]
+}
+
+// absorption returns how much the block dims every colored channel of the light going through it, on top of the 1 of every block
+func (b BlockId) absorption() light {
+	if b == AIR {
+		return 0
+	}
+	return BLOCK_TYPES[b.Type()].absorption
+}
+
+// emits returns true if a block of the palette emits light
+func (s *blockSection) emits() bool {
+	return slices.ContainsFunc(s.palette, func(b BlockId) bool {
+		return b.emission() != 0
+	})
+}
+
+/*
+newChunkLight returns the light of the blocks of a chunk on their own, before it spreads from its neighbours:
+the sky shines down every column to its first opaque block, and the blocks that emit light hold it.
+It also returns the blocks the light spreads from in the chunk, for every channel, in chunk coordinates.
+*/
+func newChunkLight(blocks *chunkStorage) (chunkLight, [lightChannels][]utils.IntVector3) {
+	var c chunkLight
+	var sources [lightChannels][]utils.IntVector3
+
+	// heights[x][z] is the lowest y the sky shines on in the column
+	var heights [CHUNK_WIDTH][CHUNK_WIDTH]int
+	highest := MIN_Y
+	for x := range CHUNK_WIDTH {
+		for z := range CHUNK_WIDTH {
+			y := MAX_Y
+			for y > MIN_Y && !blocks.get(x, y-1, z).opaque() {
+				y--
+			}
+			heights[x][z] = y
+			highest = max(highest, y)
+		}
+	}
+	// the sections above every column are lit as a whole
+	skyTop := MAX_Y
+	for i := SECTION_COUNT - 1; i >= 0 && SectionBottom(i) >= highest; i-- {
+		c.uniform[i] = skyLight
+		skyTop = SectionBottom(i)
+	}
+
+	for x := range CHUNK_WIDTH {
+		for z := range CHUNK_WIDTH {
+			// the sky light only spreads sideways into the columns around where they don't reach as low
+			spreadTo := MIN_Y
+			for _, offset := range sideOffsets {
+				nx, nz := x+offset.X, z+offset.Y
+				if nx >= 0 && nx < CHUNK_WIDTH && nz >= 0 && nz < CHUNK_WIDTH {
+					spreadTo = max(spreadTo, heights[nx][nz])
+				}
+			}
+			for y := heights[x][z]; y < skyTop; y++ {
+				c.set(x, y, z, skyLight)
+			}
+			for y := heights[x][z]; y < spreadTo; y++ {
+				sources[skyChannel] = append(sources[skyChannel], utils.IntVector3{X: x, Y: y, Z: z})
+			}
+		}
+	}
+
+	for i, s := range blocks.sections {
+		if s == nil || !s.emits() {
+			continue
+		}
+		bottom := SectionBottom(i)
+		for x := range CHUNK_WIDTH {
+			for y := range SECTION_HEIGHT {
+				for z := range CHUNK_WIDTH {
+					emission := s.get(sectionIndex(x, y, z)).emission()
+					if emission == 0 {
+						continue
+					}
+					c.set(x, bottom+y, z, c.get(x, bottom+y, z)|emission)
+					for channel := redChannel; channel < lightChannels; channel++ {
+						if emission.channel(channel) > 0 {
+							sources[channel] = append(sources[channel], utils.IntVector3{X: x, Y: bottom + y, Z: z})
+						}
+					}
+				}
+			}
+		}
+	}
+	return c, sources
+}
@@ -0,0 +1,236 @@
+package level
+
+import (
+	"sync"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+// lightNode is a block whose light is being removed, with the level it had in the channel
+type lightNode struct {
+	position utils.IntVector3
+	level    int
+}
+
+/*
+lightEngine spreads the light between the blocks of the lit chunks of a level, one update at a time.
+Light spreads with a breadth first search from the blocks that got brighter, and is removed with another one from the blocks that got darker,
+which hands the blocks still lit by other sources back to the first so they light the removed blocks again.
+Only lit chunks and the one being lit take part, a chunk takes the light of its lit neighbours when it is lit itself.
+*/
+type lightEngine struct {
+	mu       sync.Mutex
+	lighting *Chunk                          // chunk being lit, its light changes before it reaches STATUS_LIGHT
+	spread   []utils.IntVector3              // level coordinates of the blocks the light spreads from
+	removed  []lightNode                     // level coordinates of the blocks the light is removed from
+	changed  map[utils.IntVector2]SectionSet // sections of the meshes showing blocks whose light changed
+}
+
+// chunkPosition returns the coordinates of the chunk holding the block at level coordinates p and the coordinates of the block in it
+func chunkPosition(p utils.IntVector3) (utils.IntVector2, utils.IntVector3) {
+	coordinates := utils.IntVector2{X: floorDiv(p.X, CHUNK_WIDTH), Y: floorDiv(p.Z, CHUNK_WIDTH)}
+	return coordinates, utils.IntVector3{X: utils.Mod(p.X, CHUNK_WIDTH), Y: p.Y, Z: utils.Mod(p.Z, CHUNK_WIDTH)}
+}
+
+// engineLight returns the light and the block at level coordinates p, ok is false if the light engine can't change it
+func (l *Level) engineLight(p utils.IntVector3) (value light, b BlockId, ok bool) {
+	if p.Y < MIN_Y || p.Y >= MAX_Y || len(l.chunks) == 0 {
+		return 0, AIR, false
+	}
+	coordinates, local := chunkPosition(p)
+	c := l.getChunk(coordinates)
+	if c == nil {
+		return 0, AIR, false
+	}
+	return c.lightAt(coordinates, local, c == l.light.lighting)
+}
+
+// setEngineLight sets the light at level coordinates p, the meshes showing it are remeshed by flushLightChanges
+func (l *Level) setEngineLight(p utils.IntVector3, value light) {
+	coordinates, local := chunkPosition(p)
+	c := l.getChunk(coordinates)
+	if c == nil || !c.setLight(coordinates, local, value, c == l.light.lighting) || c == l.light.lighting {
+		return
+	}
+
+	if l.light.changed == nil {
+		l.light.changed = make(map[utils.IntVector2]SectionSet)
+	}
+	l.light.changed[coordinates] |= sectionsAround(p.Y)
+	var borders borderSections
+	addBorderSections(&borders, local)
+	for i, offset := range borderOffsets {
+		if borders[i] != 0 {
+			l.light.changed[coordinates.Add(offset)] |= borders[i]
+		}
+	}
+}
+
+// flushLightChanges remeshes the sections showing the blocks whose light changed
+func (l *Level) flushLightChanges() {
+	for coordinates, sections := range l.light.changed {
+		if c := l.getChunk(coordinates); c != nil {
+			c.invalidateMeshes(coordinates, sections)
+		}
+	}
+	clear(l.light.changed)
+}
+
+// spreadLight spreads the light of channel from the blocks of the spread queue to the ones around that are darker
+func (l *Level) spreadLight(channel int) {
+	e := &l.light
+	for i := 0; i < len(e.spread); i++ {
+		p := e.spread[i]
+		current, _, ok := l.engineLight(p)
+		if !ok {
+			continue
+		}
+		level := current.channel(channel)
+
+		for face, normal := range faceNormals {
+			n := p.Add(normal)
+			neighbour, b, ok := l.engineLight(n)
+			if !ok || b.opaque() {
+				continue
+			}
+			target := level - 1
+			switch {
+			case channel == skyChannel && face == faceBottom && level == MAX_LIGHT:
+				target = MAX_LIGHT // the sky light goes down without dimming
+			case channel != skyChannel:
+				target -= b.absorption().channel(channel) // the sky light is white, only the colored channels are filtered
+			}
+			if neighbour.channel(channel) < target {
+				l.setEngineLight(n, neighbour.with(channel, target))
+				e.spread = append(e.spread, n)
+			}
+		}
+	}
+	e.spread = e.spread[:0]
+}
+
+// removeLight darkens the blocks lit by the blocks of the removed queue, the ones lit by other sources are added to the spread queue
+func (l *Level) removeLight(channel int) {
+	e := &l.light
+	for i := 0; i < len(e.removed); i++ {
+		removed := e.removed[i]
+		for face, normal := range faceNormals {
+			n := removed.position.Add(normal)
+			neighbour, b, ok := l.engineLight(n)
+			level := neighbour.channel(channel)
+			if !ok || level == 0 {
+				continue
+			}
+
+			fromSky := channel == skyChannel && face == faceBottom && removed.level == MAX_LIGHT
+			if level >= removed.level && !(fromSky && level == MAX_LIGHT) {
+				e.spread = append(e.spread, n)
+				continue
+			}
+			own := b.emission().channel(channel)
+			l.setEngineLight(n, neighbour.with(channel, own))
+			e.removed = append(e.removed, lightNode{n, level})
+			if own > 0 {
+				e.spread = append(e.spread, n)
+			}
+		}
+	}
+	e.removed = e.removed[:0]
+}
+
+// updateLight updates the light around the block at p in the chunk at coordinates, which was replaced from old to b
+func (l *Level) updateLight(coordinates utils.IntVector2, p utils.IntVector3, old, b BlockId) {
+	if old.opaque() == b.opaque() && old.emission() == b.emission() && old.absorption() == b.absorption() {
+		return
+	}
+	e := &l.light
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	p = utils.IntVector3{X: coordinates.X*CHUNK_WIDTH + p.X, Y: p.Y, Z: coordinates.Y*CHUNK_WIDTH + p.Z}
+	for channel := range lightChannels {
+		current, _, ok := l.engineLight(p)
+		if !ok {
+			return
+		}
+		own := b.emission().channel(channel)
+		l.setEngineLight(p, current.with(channel, own))
+		e.removed = append(e.removed, lightNode{p, current.channel(channel)})
+		if own > 0 {
+			e.spread = append(e.spread, p)
+		}
+		if !b.opaque() {
+			// the light around comes back through the block
+			for _, normal := range faceNormals {
+				e.spread = append(e.spread, p.Add(normal))
+			}
+		}
+
+		l.removeLight(channel)
+		l.spreadLight(channel)
+	}
+	l.flushLightChanges()
+}
+
+/*
+lightChunk computes the light of the chunk at coordinates and spreads it to and from its lit neighbours, then brings it to STATUS_LIGHT.
+It returns false if the chunk moved or was already lit.
+*/
+func (l *Level) lightChunk(c *Chunk, coordinates utils.IntVector2) bool {
+	e := &l.light
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	sources, ok := c.computeLight(coordinates)
+	if !ok {
+		return false
+	}
+	e.lighting = c
+	defer func() { e.lighting = nil }()
+
+	origin := utils.IntVector3{X: coordinates.X * CHUNK_WIDTH, Y: 0, Z: coordinates.Y * CHUNK_WIDTH}
+	for channel := range lightChannels {
+		for _, p := range sources[channel] {
+			e.spread = append(e.spread, origin.Add(p))
+		}
+		l.addBorderSources(coordinates, channel)
+		l.spreadLight(channel)
+	}
+	l.flushLightChanges()
+	return c.setBlocks(coordinates, STATUS_LIGHT, nil, false)
+}
+
+// addBorderSources adds the blocks on both sides of the borders of the chunk at coordinates with its lit neighbours to the spread queue, where the light has to cross
+func (l *Level) addBorderSources(coordinates utils.IntVector2, channel int) {
+	origin := utils.IntVector3{X: coordinates.X * CHUNK_WIDTH, Y: 0, Z: coordinates.Y * CHUNK_WIDTH}
+	for _, offset := range sideOffsets {
+		neighbour := l.getChunk(coordinates.Add(offset))
+		if neighbour == nil || neighbour.statusAt(coordinates.Add(offset)) < STATUS_LIGHT {
+			continue
+		}
+
+		normal := utils.IntVector3{X: offset.X, Y: 0, Z: offset.Y}
+		for along := range CHUNK_WIDTH {
+			inside := utils.IntVector3{X: along, Y: 0, Z: along}
+			if offset.X != 0 {
+				inside.X = (offset.X + 1) / 2 * (CHUNK_WIDTH - 1)
+			} else {
+				inside.Z = (offset.Y + 1) / 2 * (CHUNK_WIDTH - 1)
+			}
+			inside = origin.Add(inside)
+
+			for y := MIN_Y; y < MAX_Y; y++ {
+				inside.Y = y
+				outside := inside.Add(normal)
+				a, _, _ := l.engineLight(inside)
+				b, _, _ := l.engineLight(outside)
+				switch {
+				case a.channel(channel) > b.channel(channel)+1:
+					l.light.spread = append(l.light.spread, inside)
+				case b.channel(channel) > a.channel(channel)+1:
+					l.light.spread = append(l.light.spread, outside)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package level
+
+import (
+	"testing"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+// newLitTestLevel returns a level of the 3 by 3 chunks around the origin filled by fill, lit one after the other
+func newLitTestLevel(t *testing.T, fill func(p utils.IntVector3) BlockId) *Level {
+	l, m := newTestLevel(1)
+	var chunks []*Chunk
+	for x := -1; x <= 1; x++ {
+		for z := -1; z <= 1; z++ {
+			coordinates := utils.IntVector2{X: x, Y: z}
+			c := addTestChunk(l, m, coordinates)
+			var blocks chunkBlocks
+			for bx := range CHUNK_WIDTH {
+				for y := MIN_Y; y < MAX_Y; y++ {
+					for bz := range CHUNK_WIDTH {
+						blocks[bx][y-MIN_Y][bz] = fill(utils.IntVector3{X: x*CHUNK_WIDTH + bx, Y: y, Z: z*CHUNK_WIDTH + bz})
+					}
+				}
+			}
+			c.setBlocks(coordinates, STATUS_FEATURES, &blocks, false)
+			chunks = append(chunks, c)
+		}
+	}
+	for _, c := range chunks {
+		if !l.lightChunk(c, c.getCoordinates()) {
+			t.Fatalf("the chunk at %v can't be lit", c.getCoordinates())
+		}
+	}
+	for _, c := range chunks {
+		c.setBlocks(c.getCoordinates(), STATUS_FULL, nil, false)
+	}
+	return l
+}
+
+func setTestBlock(l *Level, p utils.IntVector3, b BlockId) {
+	coordinates, local := chunkPosition(p)
+	l.getChunk(coordinates).setBlock(local, b)
+}
+
+func checkLight(t *testing.T, l *Level, channel int, p utils.IntVector3, want int) {
+	t.Helper()
+	value, _, ok := l.engineLight(p)
+	if !ok {
+		t.Fatalf("the light at %v isn't computed", p)
+	}
+	if got := value.channel(channel); got != want {
+		t.Errorf("the light of channel %d at %v is %d, want %d", channel, p, got, want)
+	}
+}
+
+func TestSkyLight(t *testing.T) {
+	// a roof over x and z from -2 to 2, across 4 chunks
+	l := newLitTestLevel(t, func(p utils.IntVector3) BlockId {
+		roof := p.Y == 10 && p.X >= -2 && p.X <= 2 && p.Z >= -2 && p.Z <= 2
+		if p.Y < 0 || roof {
+			return STONE
+		}
+		return AIR
+	})
+
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 7, Y: 0, Z: 7}, MAX_LIGHT)
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 7, Y: -1, Z: 7}, 0)
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 0, Y: 5, Z: 0}, MAX_LIGHT-3)
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 2, Y: 9, Z: -2}, MAX_LIGHT-1)
+
+	// a hole in the roof lets the sky shine down to the ground
+	setTestBlock(l, utils.IntVector3{X: 0, Y: 10, Z: 0}, AIR)
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 0, Y: 0, Z: 0}, MAX_LIGHT)
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 1, Y: 5, Z: 1}, MAX_LIGHT-2)
+
+	setTestBlock(l, utils.IntVector3{X: 0, Y: 10, Z: 0}, STONE)
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 0, Y: 0, Z: 0}, MAX_LIGHT-3)
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 1, Y: 5, Z: 1}, MAX_LIGHT-2)
+
+	// a single block shades the block under it
+	setTestBlock(l, utils.IntVector3{X: 8, Y: 5, Z: 8}, STONE)
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 8, Y: 4, Z: 8}, MAX_LIGHT-1)
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 8, Y: 5, Z: 8}, 0)
+}
+
+func TestBlockLight(t *testing.T) {
+	furnace, _ := blockByName("furnace[lit=true]")
+	emission := furnace.emission().channel(redChannel)
+	if emission == 0 {
+		t.Fatal("a lit furnace doesn't emit light")
+	}
+
+	// two tunnels along x, closed to the sky, with a furnace in the deepest one before it is lit
+	l := newLitTestLevel(t, func(p utils.IntVector3) BlockId {
+		switch {
+		case p.Y >= 0:
+			return AIR
+		case p.X == -3 && p.Y == -20 && p.Z == 0:
+			return furnace
+		case (p.Y == -10 || p.Y == -20) && p.Z == 0 && p.X >= -10 && p.X <= 10:
+			return AIR
+		}
+		return STONE
+	})
+
+	// the light of the furnace crosses into the chunk lit after its own
+	checkLight(t, l, redChannel, utils.IntVector3{X: -3, Y: -20, Z: 0}, emission)
+	checkLight(t, l, redChannel, utils.IntVector3{X: -1, Y: -20, Z: 0}, emission-2)
+	checkLight(t, l, redChannel, utils.IntVector3{X: 3, Y: -20, Z: 0}, emission-6)
+	checkLight(t, l, skyChannel, utils.IntVector3{X: 3, Y: -20, Z: 0}, 0)
+
+	setTestBlock(l, utils.IntVector3{X: 2, Y: -10, Z: 0}, furnace)
+	checkLight(t, l, redChannel, utils.IntVector3{X: -1, Y: -10, Z: 0}, emission-3)
+	checkLight(t, l, redChannel, utils.IntVector3{X: 10, Y: -10, Z: 0}, emission-8)
+	checkLight(t, l, redChannel, utils.IntVector3{X: 2, Y: -11, Z: 0}, 0)
+
+	// a block in the tunnel stops the light, breaking the furnace removes it
+	setTestBlock(l, utils.IntVector3{X: 5, Y: -10, Z: 0}, STONE)
+	checkLight(t, l, redChannel, utils.IntVector3{X: 6, Y: -10, Z: 0}, 0)
+	setTestBlock(l, utils.IntVector3{X: 2, Y: -10, Z: 0}, AIR)
+	checkLight(t, l, redChannel, utils.IntVector3{X: -1, Y: -10, Z: 0}, 0)
+	checkLight(t, l, redChannel, utils.IntVector3{X: 2, Y: -10, Z: 0}, 0)
+}
+
+func TestColoredLight(t *testing.T) {
+	glowstone, _ := blockByName("glowstone")
+	redGlass, _ := blockByName("red_glass")
+	blueLamp, _ := blockByName("blue_lamp")
+	emission := glowstone.emission()
+
+	// a tunnel along x closed to the sky, with glowstone on one side of red glass
+	l := newLitTestLevel(t, func(p utils.IntVector3) BlockId {
+		switch {
+		case p.Y >= 0:
+			return AIR
+		case p.Y == -20 && p.Z == 0 && p.X == -5:
+			return glowstone
+		case p.Y == -20 && p.Z == 0 && p.X == 0:
+			return redGlass
+		case p.Y == -20 && p.Z == 0 && p.X >= -10 && p.X <= 10:
+			return AIR
+		}
+		return STONE
+	})
+
+	// the glass lets the red light through and stops the others
+	checkLight(t, l, redChannel, utils.IntVector3{X: 1, Y: -20, Z: 0}, emission.channel(redChannel)-6)
+	checkLight(t, l, greenChannel, utils.IntVector3{X: -1, Y: -20, Z: 0}, emission.channel(greenChannel)-4)
+	checkLight(t, l, greenChannel, utils.IntVector3{X: 1, Y: -20, Z: 0}, 0)
+	checkLight(t, l, blueChannel, utils.IntVector3{X: 1, Y: -20, Z: 0}, 0)
+
+	setTestBlock(l, utils.IntVector3{X: 0, Y: -20, Z: 0}, AIR)
+	checkLight(t, l, greenChannel, utils.IntVector3{X: 1, Y: -20, Z: 0}, emission.channel(greenChannel)-6)
+	setTestBlock(l, utils.IntVector3{X: 0, Y: -20, Z: 0}, redGlass)
+	checkLight(t, l, greenChannel, utils.IntVector3{X: 1, Y: -20, Z: 0}, 0)
+
+	// the channels spread on their own, the blue lamp doesn't change the red light
+	setTestBlock(l, utils.IntVector3{X: 5, Y: -20, Z: 0}, blueLamp)
+	checkLight(t, l, blueChannel, utils.IntVector3{X: 3, Y: -20, Z: 0}, blueLamp.emission().channel(blueChannel)-2)
+	checkLight(t, l, redChannel, utils.IntVector3{X: 3, Y: -20, Z: 0}, emission.channel(redChannel)-8)
+}
+
+func TestLightInMesh(t *testing.T) {
+	l := newLitTestLevel(t, func(p utils.IntVector3) BlockId {
+		if p.Y < 0 || p.Y == 10 && p.X >= -2 && p.X <= 2 && p.Z >= -2 && p.Z <= 2 {
+			return STONE
+		}
+		return AIR
+	})
+	c := l.getChunk(utils.IntVector2{})
+	c.generateMesh(l)
+
+	// the top of the ground is drawn with the light of the air above it, the vertices next to the roof blend with the shade under it
+	section, _ := sectionOf(-1)
+	mesh := c.SectionMesh(section).Solid
+	for i := 0; i < len(mesh); i += VERTEX_SIZE {
+		if int(mesh[i]&0xF) != faceTop {
+			continue
+		}
+		p := vertexPosition(mesh[i])
+		if sky := light(mesh[i+2]).channel(skyChannel); p.X() >= 4 && sky != MAX_LIGHT {
+			t.Errorf("the ground at %v outside the roof has the sky light %d", p, sky)
+		}
+	}
+}
+
+func TestSmoothLightInMesh(t *testing.T) {
+	pillar := utils.IntVector3{X: 6, Y: 0, Z: 4}
+	l := newLitTestLevel(t, func(p utils.IntVector3) BlockId {
+		if p.Y < 0 || p == pillar || p.Y == 10 && p.X >= -2 && p.X <= 2 && p.Z >= -2 && p.Z <= 2 {
+			return STONE
+		}
+		return AIR
+	})
+	c := l.getChunk(utils.IntVector2{})
+	c.generateMesh(l)
+
+	// every vertex of the top of the ground has the average sky light of the air touching its corner, the pillar is left out
+	section, _ := sectionOf(-1)
+	mesh := c.SectionMesh(section).Solid
+	smooth := false
+	for i := 0; i < len(mesh); i += QUAD_VERTICES * VERTEX_SIZE {
+		if int(mesh[i]&0xF) != faceTop {
+			continue
+		}
+		for j := i; j < i+QUAD_VERTICES*VERTEX_SIZE; j += VERTEX_SIZE {
+			p := vertexPosition(mesh[j])
+			sum, count := 0, 0
+			for _, dx := range [2]int{-1, 0} {
+				for _, dz := range [2]int{-1, 0} {
+					value, b, _ := l.engineLight(utils.IntVector3{X: int(p.X()) + dx, Y: 0, Z: int(p.Z()) + dz})
+					if !b.opaque() {
+						sum += value.channel(skyChannel)
+						count++
+					}
+				}
+			}
+			if sky := light(mesh[j+2]).channel(skyChannel); sky != sum/count {
+				t.Errorf("the ground at %v has the sky light %d, want %d", p, sky, sum/count)
+			}
+			smooth = smooth || light(mesh[j+2]) != light(mesh[i+2])
+		}
+	}
+	if !smooth {
+		t.Error("every quad of the ground has the same light at all its vertices")
+	}
+}
@@ -89,6 +89,10 @@ func (m *meshBuilder) newWorker() {
 			delete(m.queueItems, chunk)
 			m.mu.Unlock()
 
+			if chunk.Status() != STATUS_FULL {
+				// meshed once its generation is over
+				continue
+			}
 			chunk.generateMesh(m.level)
 		}
 	}()
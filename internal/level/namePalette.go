@@ -0,0 +1,71 @@
+package level
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+namePalette maps the blocks of a saved payload to small indices and keeps their state names,
+so saved chunks don't depend on the ids LoadBlocks assigned when they were saved.
+It is encoded as: count (uint16) | for every block: name length (1 byte) | name
+*/
+type namePalette struct {
+	indices map[BlockId]uint16
+	names   []string
+}
+
+func newNamePalette() *namePalette {
+	return &namePalette{indices: make(map[BlockId]uint16)}
+}
+
+func (p *namePalette) index(b BlockId) uint16 {
+	if i, ok := p.indices[b]; ok {
+		return i
+	}
+	i := uint16(len(p.names))
+	p.indices[b] = i
+	p.names = append(p.names, b.StateName())
+	return i
+}
+
+func (p *namePalette) encode(buf *bytes.Buffer) {
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(p.names))))
+	for _, name := range p.names {
+		buf.WriteByte(byte(len(name)))
+		buf.WriteString(name)
+	}
+}
+
+// decodeNamePalette returns the current ids of the blocks of an encoded palette,
+// blocks that aren't in the registry anymore are replaced with AIR
+func decodeNamePalette(r io.Reader) ([]BlockId, error) {
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, fmt.Errorf("binary.Read(): %w", err)
+	}
+
+	ids := make([]BlockId, count)
+	for i := range ids {
+		var length [1]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return nil, fmt.Errorf("io.ReadFull(): %w", err)
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, fmt.Errorf("io.ReadFull(): %w", err)
+		}
+		ids[i], _ = blockByName(string(name))
+	}
+	return ids, nil
+}
+
+func paletteBlock(ids []BlockId, i uint16) (BlockId, error) {
+	if int(i) >= len(ids) {
+		return AIR, errors.New("block index out of the palette")
+	}
+	return ids[i], nil
+}
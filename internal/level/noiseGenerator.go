@@ -0,0 +1,139 @@
+package level
+
+import (
+	"math/rand"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+// noiseGenerator generates terrain from a seeded heightmap shaped by the biomes,
+// 3D density noise adds cliffs and overhangs and carvers dig caves
+type noiseGenerator struct {
+	seed         int64
+	heightNoise  *utils.Noise
+	densityNoise *utils.Noise
+	climate      *climateMap
+	caves        *caveCarver
+}
+
+// column holds the biome blended parameters of a column of blocks
+type column struct {
+	height      float32
+	overhang    float32
+	caveDensity float32
+	biome       *biome // dominant biome
+}
+
+func NewNoiseGenerator(seed int64) Generator {
+	return &noiseGenerator{
+		seed:         seed,
+		heightNoise:  utils.NewNoise(utils.DeriveSeed(seed, "height")),
+		densityNoise: utils.NewNoise(utils.DeriveSeed(seed, "density")),
+		climate:      newClimateMap(seed),
+		caves:        newCaveCarver(seed),
+	}
+}
+
+func (g *noiseGenerator) Biome(x, z int) BiomeId {
+	return g.climate.Biome(x, z)
+}
+
+// column returns the parameters of the column at x, z blended between the neighbouring biomes
+func (g *noiseGenerator) column(x, z int) column {
+	n := g.heightNoise.FractalNoise2(float32(x), float32(z), 6)
+	weights := g.climate.climate(x, z).weights()
+
+	c := column{biome: &BIOMES[weights[0].id]}
+	for _, w := range weights {
+		b := &BIOMES[w.id]
+		c.height += w.weight * b.height(n)
+		c.overhang += w.weight * b.overhang
+		c.caveDensity += w.weight * b.caveDensity
+	}
+	return c
+}
+
+func (g *noiseGenerator) columns(coordinates utils.IntVector2) *[CHUNK_WIDTH][CHUNK_WIDTH]column {
+	var columns [CHUNK_WIDTH][CHUNK_WIDTH]column
+	for i := range CHUNK_WIDTH {
+		for j := range CHUNK_WIDTH {
+			columns[i][j] = g.column(i+coordinates.X*CHUNK_WIDTH, j+coordinates.Y*CHUNK_WIDTH)
+		}
+	}
+	return &columns
+}
+
+func (g *noiseGenerator) GenerateTerrain(coordinates utils.IntVector2) *chunkBlocks {
+	var blocks chunkBlocks
+	g.generateTerrain(coordinates, g.columns(coordinates), &blocks)
+	return &blocks
+}
+
+func (g *noiseGenerator) carve(coordinates utils.IntVector2, blocks *chunkBlocks) {
+	g.caves.carve(coordinates, g.columns(coordinates), blocks)
+}
+
+// generateTerrain fills the solid terrain from the 3D density, then the seas and the biome's surface blocks
+func (g *noiseGenerator) generateTerrain(coordinates utils.IntVector2, columns *[CHUNK_WIDTH][CHUNK_WIDTH]column, blocks *chunkBlocks) {
+	density := newNoiseGrid(coordinates, func(x, y, z float32) float32 {
+		return g.densityNoise.ScaledFractalNoise3(x, y, z, 0.015, 3)
+	})
+
+	for i := range CHUNK_WIDTH {
+		for j := range CHUNK_WIDTH {
+			c := columns[i][j]
+			x := i + coordinates.X*CHUNK_WIDTH
+			z := j + coordinates.Y*CHUNK_WIDTH
+
+			// density is positive for solid blocks, the noise can only make a difference close to the heightmap
+			top := min(int(c.height+c.overhang)+1, MAX_Y)
+			for y := MIN_Y; y < top; y++ {
+				if y == MIN_Y || c.height-float32(y)+c.overhang*density.sample(x, y, z) > 0 {
+					blocks[i][y-MIN_Y][j] = STONE
+				}
+			}
+
+			depth := 0 // solid blocks since the last non solid block above
+			underwater := false
+			for y := MAX_Y - 1; y >= MIN_Y; y-- {
+				k := y - MIN_Y
+				if blocks[i][k][j] == AIR {
+					if y == WATER_LEVEL && c.biome.frozen {
+						blocks[i][k][j] = ICE
+					} else if y <= WATER_LEVEL {
+						blocks[i][k][j] = WATER
+					}
+					depth = 0
+					continue
+				}
+
+				if depth == 0 {
+					underwater = y <= WATER_LEVEL
+				}
+
+				surface, filler := c.biome.surface, c.biome.filler
+				if underwater {
+					surface, filler = c.biome.seabed, c.biome.seabed
+				}
+
+				if depth == 0 {
+					blocks[i][k][j] = surface
+				} else if depth <= c.biome.fillerDepth {
+					blocks[i][k][j] = filler
+				}
+				depth++
+			}
+		}
+	}
+}
+
+func (g *noiseGenerator) placeFeatures(coordinates utils.IntVector2, blocks *chunkBlocks) map[utils.IntVector2][]blockWrite {
+	placeOres(g.seed, coordinates, blocks)
+
+	w := newFeatureWriter(coordinates, blocks)
+	rng := rand.New(rand.NewSource(utils.DerivePositionSeed(g.seed, "features", coordinates.X, coordinates.Y)))
+	placeFeatures(w, rng, func(x, z int) *biome {
+		return &BIOMES[g.climate.Biome(x, z)]
+	})
+	return w.outside
+}
@@ -0,0 +1,35 @@
+package level
+
+import (
+	"testing"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+func generate(generator Generator, coordinates utils.IntVector2) *chunkBlocks {
+	return GenerateArea(generator, coordinates, coordinates).chunk(coordinates).dense()
+}
+
+func TestNoiseGeneratorSameSeed(t *testing.T) {
+	for _, coordinates := range []utils.IntVector2{{X: 0, Y: 0}, {X: -3, Y: 7}, {X: 120, Y: -45}} {
+		a, err := encodeChunk(STATUS_FULL, generate(NewNoiseGenerator(42), coordinates))
+		if err != nil {
+			t.Fatalf("encodeChunk(): %v", err)
+		}
+		b, err := encodeChunk(STATUS_FULL, generate(NewNoiseGenerator(42), coordinates))
+		if err != nil {
+			t.Fatalf("encodeChunk(): %v", err)
+		}
+
+		if string(a) != string(b) {
+			t.Errorf("chunk %v differs between two generations with the same seed", coordinates)
+		}
+	}
+}
+
+func TestNoiseGeneratorDifferentSeed(t *testing.T) {
+	coordinates := utils.IntVector2{X: 4, Y: 4}
+	if *generate(NewNoiseGenerator(1), coordinates) == *generate(NewNoiseGenerator(2), coordinates) {
+		t.Errorf("chunk %v is the same for seeds 1 and 2", coordinates)
+	}
+}
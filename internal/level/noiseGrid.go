@@ -0,0 +1,78 @@
+package level
+
+import "github.com/vparent05/minecraft_go/internal/utils"
+
+// Distance in blocks between two samples of a noiseGrid
+const (
+	NOISE_GRID_STEP_XZ = 4
+	NOISE_GRID_STEP_Y  = 4
+)
+
+/*
+noiseGrid samples a 3D function on a coarse lattice covering a chunk
+and trilinearly interpolates between the samples,
+which is much cheaper than evaluating 3D noise for every block.
+The lattice is aligned on level coordinates so neighbouring chunks interpolate the same values at their border.
+*/
+type noiseGrid struct {
+	origin     utils.IntVector3 // level coordinates of the first sample
+	nx, ny, nz int
+	values     []float32
+}
+
+func floorDiv(a, b int) int {
+	return (a - utils.Mod(a, b)) / b
+}
+
+func newNoiseGrid(coordinates utils.IntVector2, f func(x, y, z float32) float32) *noiseGrid {
+	startX := floorDiv(coordinates.X*CHUNK_WIDTH, NOISE_GRID_STEP_XZ)
+	startZ := floorDiv(coordinates.Y*CHUNK_WIDTH, NOISE_GRID_STEP_XZ)
+	endX := floorDiv((coordinates.X+1)*CHUNK_WIDTH-1, NOISE_GRID_STEP_XZ) + 1
+	endZ := floorDiv((coordinates.Y+1)*CHUNK_WIDTH-1, NOISE_GRID_STEP_XZ) + 1
+
+	g := &noiseGrid{
+		origin: utils.IntVector3{X: startX * NOISE_GRID_STEP_XZ, Y: MIN_Y, Z: startZ * NOISE_GRID_STEP_XZ},
+		nx:     endX - startX + 1,
+		ny:     (CHUNK_HEIGHT-1)/NOISE_GRID_STEP_Y + 2,
+		nz:     endZ - startZ + 1,
+	}
+	g.values = make([]float32, g.nx*g.ny*g.nz)
+
+	for i := range g.nx {
+		for j := range g.ny {
+			for k := range g.nz {
+				g.values[g.index(i, j, k)] = f(
+					float32(g.origin.X+i*NOISE_GRID_STEP_XZ),
+					float32(g.origin.Y+j*NOISE_GRID_STEP_Y),
+					float32(g.origin.Z+k*NOISE_GRID_STEP_XZ),
+				)
+			}
+		}
+	}
+	return g
+}
+
+func (g *noiseGrid) index(i, j, k int) int {
+	return (i*g.ny+j)*g.nz + k
+}
+
+// sample returns the interpolated value at level coordinates x, y, z inside the grid
+func (g *noiseGrid) sample(x, y, z int) float32 {
+	dx, dy, dz := x-g.origin.X, y-g.origin.Y, z-g.origin.Z
+	i, j, k := dx/NOISE_GRID_STEP_XZ, dy/NOISE_GRID_STEP_Y, dz/NOISE_GRID_STEP_XZ
+	u := float32(dx%NOISE_GRID_STEP_XZ) / NOISE_GRID_STEP_XZ
+	v := float32(dy%NOISE_GRID_STEP_Y) / NOISE_GRID_STEP_Y
+	w := float32(dz%NOISE_GRID_STEP_XZ) / NOISE_GRID_STEP_XZ
+
+	lerp := func(t, a, b float32) float32 { return a + t*(b-a) }
+	return lerp(u,
+		lerp(v,
+			lerp(w, g.values[g.index(i, j, k)], g.values[g.index(i, j, k+1)]),
+			lerp(w, g.values[g.index(i, j+1, k)], g.values[g.index(i, j+1, k+1)]),
+		),
+		lerp(v,
+			lerp(w, g.values[g.index(i+1, j, k)], g.values[g.index(i+1, j, k+1)]),
+			lerp(w, g.values[g.index(i+1, j+1, k)], g.values[g.index(i+1, j+1, k+1)]),
+		),
+	)
+}
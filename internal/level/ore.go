@@ -0,0 +1,65 @@
+package level
+
+import (
+	"math/rand"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+type ore struct {
+	block    BlockId
+	minY     int     // lowest height of a vein's first block
+	maxY     int     // highest height of a vein's first block
+	veinSize int     // blocks in a vein
+	veins    float32 // average number of veins per chunk
+}
+
+// ORES are placed in this order, a vein only replaces STONE so the first ores win where veins overlap
+var ORES []ore
+
+// loadOres is called by LoadBlocks once the ids of the ore blocks are known
+func loadOres() {
+	ORES = []ore{
+		{COAL_ORE, 5, 128, 14, 18},
+		{IRON_ORE, -24, 64, 8, 12},
+		{COPPER_ORE, 30, 90, 10, 6},
+		{GOLD_ORE, -48, 32, 7, 3},
+		{DIAMOND_ORE, MIN_Y + 4, 16, 5, 1.2},
+	}
+}
+
+var veinDirections = [...]utils.IntVector3{
+	{X: 1, Y: 0, Z: 0}, {X: -1, Y: 0, Z: 0},
+	{X: 0, Y: 1, Z: 0}, {X: 0, Y: -1, Z: 0},
+	{X: 0, Y: 0, Z: 1}, {X: 0, Y: 0, Z: -1},
+}
+
+/*
+placeOres grows the veins of every ore as random walks through the stone of the chunk.
+Veins are cut at the chunk borders, so the result only depends on the seed, the coordinates and the blocks of the chunk.
+*/
+func placeOres(seed int64, coordinates utils.IntVector2, blocks *chunkBlocks) {
+	rng := rand.New(rand.NewSource(utils.DerivePositionSeed(seed, "ores", coordinates.X, coordinates.Y)))
+
+	for _, o := range ORES {
+		veins := int(o.veins)
+		if rng.Float32() < o.veins-float32(veins) {
+			veins++
+		}
+
+		for range veins {
+			p := utils.IntVector3{
+				X: rng.Intn(CHUNK_WIDTH),
+				Y: o.minY + rng.Intn(o.maxY-o.minY+1),
+				Z: rng.Intn(CHUNK_WIDTH),
+			}
+			for range o.veinSize {
+				if p.X >= 0 && p.X < CHUNK_WIDTH && p.Y >= MIN_Y && p.Y < MAX_Y && p.Z >= 0 && p.Z < CHUNK_WIDTH &&
+					blocks[p.X][p.Y-MIN_Y][p.Z] == STONE {
+					blocks[p.X][p.Y-MIN_Y][p.Z] = o.block
+				}
+				p = p.Add(veinDirections[rng.Intn(len(veinDirections))])
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package level
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+// blockWrite is a block placed by a feature, at chunk relative x and z
+type blockWrite struct {
+	position utils.IntVector3
+	block    BlockId
+}
+
+const (
+	priorityAir = iota
+	priorityPlant
+	priorityLeaves
+	priorityFixed // terrain and solid feature blocks, never replaced by a feature
+)
+
+func featurePriority(b BlockId) int {
+	switch b {
+	case AIR:
+		return priorityAir
+	case TALL_GRASS:
+		return priorityPlant
+	case LEAVES:
+		return priorityLeaves
+	default:
+		return priorityFixed
+	}
+}
+
+/*
+mergeFeatureBlock returns the block left when a feature writes block over current.
+The merge is commutative and associative, so the blocks of overlapping features
+end up the same whatever order the chunks are generated in.
+*/
+func mergeFeatureBlock(current, block BlockId) BlockId {
+	pc, pb := featurePriority(current), featurePriority(block)
+	if pb > pc || pb == pc && pc != priorityFixed && block > current {
+		return block
+	}
+	return current
+}
+
+func mergeWrites(blocks *chunkBlocks, writes []blockWrite) {
+	for _, w := range writes {
+		p := w.position
+		blocks[p.X][p.Y-MIN_Y][p.Z] = mergeFeatureBlock(blocks[p.X][p.Y-MIN_Y][p.Z], w.block)
+	}
+}
+
+/*
+pendingWrites holds the blocks features placed in chunks that weren't generated yet,
+they are merged into the chunk once it is generated or loaded.
+They are kept in the world storage, or in memory when the level isn't saved.
+*/
+type pendingWrites struct {
+	mu      sync.Mutex
+	storage *WorldStorage
+	writes  map[utils.IntVector2][]blockWrite
+}
+
+func newPendingWrites(storage *WorldStorage) *pendingWrites {
+	return &pendingWrites{
+		storage: storage,
+		writes:  make(map[utils.IntVector2][]blockWrite),
+	}
+}
+
+// add must be called with p.mu held
+func (p *pendingWrites) add(coordinates utils.IntVector2, writes []blockWrite) error {
+	if len(writes) == 0 {
+		return nil
+	}
+	if p.storage == nil {
+		p.writes[coordinates] = append(p.writes[coordinates], writes...)
+		return nil
+	}
+
+	data, err := p.storage.read(pendingDirectory, coordinates)
+	if err != nil {
+		return fmt.Errorf("read(): %w", err)
+	}
+	previous, err := decodeWrites(data)
+	if err != nil {
+		return fmt.Errorf("decodeWrites(): %w", err)
+	}
+
+	err = p.storage.write(pendingDirectory, coordinates, encodeWrites(append(previous, writes...)))
+	if err != nil {
+		return fmt.Errorf("write(): %w", err)
+	}
+	return nil
+}
+
+// take removes and returns the writes waiting for the chunk at coordinates, it must be called with p.mu held
+func (p *pendingWrites) take(coordinates utils.IntVector2) ([]blockWrite, error) {
+	if p.storage == nil {
+		writes := p.writes[coordinates]
+		delete(p.writes, coordinates)
+		return writes, nil
+	}
+
+	data, err := p.storage.read(pendingDirectory, coordinates)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	writes, err := decodeWrites(data)
+	if err != nil {
+		return nil, fmt.Errorf("decodeWrites(): %w", err)
+	}
+
+	err = p.storage.write(pendingDirectory, coordinates, nil)
+	if err != nil {
+		return nil, fmt.Errorf("write(): %w", err)
+	}
+	return writes, nil
+}
+
+const pendingFormatVersion = 3
+
+// pendingWriteSize is the number of bytes of an encoded write
+const pendingWriteSize = 6
+
+// encodeWrites encodes the writes as: format version (1 byte) | compression (1 byte) | namePalette | x (1 byte), y (int16), z (1 byte), palette index (uint16) per write
+func encodeWrites(writes []blockWrite) []byte {
+	palette := newNamePalette()
+	raw := make([]byte, 0, pendingWriteSize*len(writes))
+	for _, w := range writes {
+		raw = append(raw, byte(w.position.X))
+		raw = binary.BigEndian.AppendUint16(raw, uint16(int16(w.position.Y)))
+		raw = append(raw, byte(w.position.Z))
+		raw = binary.BigEndian.AppendUint16(raw, palette.index(w.block))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(pendingFormatVersion)
+	buf.WriteByte(compressionNone)
+	palette.encode(&buf)
+	buf.Write(raw)
+	return buf.Bytes()
+}
+
+func decodeWrites(data []byte) ([]blockWrite, error) {
+	if data == nil {
+		return nil, nil
+	}
+	if len(data) < 2 {
+		return nil, errors.New("truncated pending writes payload")
+	}
+	if data[1] != compressionNone {
+		return nil, fmt.Errorf("unsupported pending writes compression %d", data[1])
+	}
+
+	if data[0] != pendingFormatVersion {
+		return nil, fmt.Errorf("unsupported pending writes format version %d", data[0])
+	}
+
+	r := bytes.NewReader(data[2:])
+	ids, err := decodeNamePalette(r)
+	if err != nil {
+		return nil, fmt.Errorf("decodeNamePalette(): %w", err)
+	}
+	if r.Len()%pendingWriteSize != 0 {
+		return nil, errors.New("truncated pending writes payload")
+	}
+
+	raw := data[len(data)-r.Len():]
+	writes := make([]blockWrite, 0, len(raw)/pendingWriteSize)
+	for i := 0; i < len(raw); i += pendingWriteSize {
+		position := utils.IntVector3{X: int(raw[i]), Y: int(int16(binary.BigEndian.Uint16(raw[i+1:]))), Z: int(raw[i+3])}
+		block, err := paletteBlock(ids, binary.BigEndian.Uint16(raw[i+4:]))
+		if err != nil {
+			return nil, fmt.Errorf("paletteBlock(): %w", err)
+		}
+		writes = append(writes, blockWrite{position, block})
+	}
+	return writes, nil
+}
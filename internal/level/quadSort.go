@@ -0,0 +1,52 @@
+package level
+
+import "github.com/go-gl/mathgl/mgl32"
+
+// vertexPosition returns the position in blocks of the vertex starting with word, relative to the bottom of its section
+func vertexPosition(word uint32) mgl32.Vec3 {
+	return mgl32.Vec3{
+		float32(word>>24&0xFF) / modelUnits,
+		float32(word>>12&0xFFF) / modelUnits,
+		float32(word>>4&0xFF) / modelUnits,
+	}
+}
+
+// quadDistance returns the squared distance from camera to the center of the quad starting at vertices[i]
+func quadDistance(vertices []uint32, i int, camera mgl32.Vec3) float32 {
+	var center mgl32.Vec3
+	for j := range QUAD_VERTICES {
+		center = center.Add(vertexPosition(vertices[i+j*VERTEX_SIZE]))
+	}
+	d := center.Mul(1.0 / QUAD_VERTICES).Sub(camera)
+	return d.Dot(d)
+}
+
+/*
+SortQuads orders the quads of a ChunkMesh back to front as seen from camera, relative to the bottom of the section, by the distance to their centers.
+It is an insertion sort, fast on quads already sorted for a camera close by, so the same vertices can be sorted again every time the camera moves.
+*/
+func SortQuads(vertices []uint32, camera mgl32.Vec3) {
+	const quadSize = QUAD_VERTICES * VERTEX_SIZE
+	distances := make([]float32, Quads(vertices))
+	for i := range distances {
+		distances[i] = quadDistance(vertices, i*quadSize, camera)
+	}
+
+	var quad [quadSize]uint32
+	for i := 1; i < len(distances); i++ {
+		distance := distances[i]
+		j := i
+		for j > 0 && distances[j-1] < distance {
+			j--
+		}
+		if j == i {
+			continue
+		}
+
+		copy(quad[:], vertices[i*quadSize:])
+		copy(vertices[(j+1)*quadSize:(i+1)*quadSize], vertices[j*quadSize:i*quadSize])
+		copy(vertices[j*quadSize:], quad[:])
+		copy(distances[j+1:i+1], distances[j:i])
+		distances[j] = distance
+	}
+}
@@ -0,0 +1,46 @@
+package level
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+func TestSortQuadsBackToFront(t *testing.T) {
+	l, m := newTestLevel(1)
+	c := addTestChunk(l, m, utils.IntVector2{})
+	var blocks chunkBlocks
+	for x := 1; x < CHUNK_WIDTH; x += 2 {
+		blocks[x][-MIN_Y][7] = WATER
+	}
+	c.setBlocks(c.getCoordinates(), STATUS_FULL, &blocks, false)
+	c.generateMesh(l)
+
+	section, _ := sectionOf(0)
+	vertices := slices.Clone(c.SectionMesh(section).Transparent)
+	quads := func() [][]uint32 {
+		q := slices.Collect(slices.Chunk(slices.Clone(vertices), QUAD_VERTICES*VERTEX_SIZE))
+		slices.SortFunc(q, slices.Compare)
+		return q
+	}
+	unsorted := quads()
+	if len(unsorted) != 6*7 {
+		t.Fatalf("the mesh has %d transparent quads, want the faces of 7 blocks", len(unsorted))
+	}
+
+	// the camera moves from one end of the row to the other
+	for _, camera := range []mgl32.Vec3{{-5, 0.5, 7.5}, {20, 0.5, 7.5}, {7.5, 5, 7.5}} {
+		SortQuads(vertices, camera)
+		for i := 1; i < Quads(vertices); i++ {
+			previous := quadDistance(vertices, (i-1)*QUAD_VERTICES*VERTEX_SIZE, camera)
+			if current := quadDistance(vertices, i*QUAD_VERTICES*VERTEX_SIZE, camera); current > previous {
+				t.Fatalf("from %v, quad %d is further than the one drawn before it (%f > %f)", camera, i, current, previous)
+			}
+		}
+		if !slices.EqualFunc(quads(), unsorted, slices.Equal) {
+			t.Fatalf("sorting for %v changed the quads", camera)
+		}
+	}
+}
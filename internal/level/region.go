@@ -0,0 +1,198 @@
+package level
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+/*
+A region file stores the chunks of a REGION_WIDTH x REGION_WIDTH area.
+The file is split into sectors of REGION_SECTOR_SIZE bytes, the first sectors hold the header:
+for every chunk of the region, its first sector (uint32) and its payload length in bytes (uint32).
+A first sector of 0 means the chunk was never saved.
+Every payload starts on a sector boundary and spans as many contiguous sectors as needed.
+*/
+
+const REGION_WIDTH = 32
+const REGION_SECTOR_SIZE = 4096
+
+const regionHeaderEntrySize = 8
+const regionHeaderSectors = (REGION_WIDTH*REGION_WIDTH*regionHeaderEntrySize + REGION_SECTOR_SIZE - 1) / REGION_SECTOR_SIZE
+
+type regionEntry struct {
+	sector uint32
+	length uint32
+}
+
+func (e regionEntry) sectorCount() int {
+	return sectorsFor(int(e.length))
+}
+
+type regionFile struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries [REGION_WIDTH * REGION_WIDTH]regionEntry
+	used    []bool // sector allocation map
+}
+
+func sectorsFor(length int) int {
+	return (length + REGION_SECTOR_SIZE - 1) / REGION_SECTOR_SIZE
+}
+
+// regionCoords returns the coordinates of the region containing the chunk and the chunk's index in that region
+func regionCoords(chunkCoordinates utils.IntVector2) (utils.IntVector2, int) {
+	localX := utils.Mod(chunkCoordinates.X, REGION_WIDTH)
+	localZ := utils.Mod(chunkCoordinates.Y, REGION_WIDTH)
+	return utils.IntVector2{
+		X: (chunkCoordinates.X - localX) / REGION_WIDTH,
+		Y: (chunkCoordinates.Y - localZ) / REGION_WIDTH,
+	}, localX + localZ*REGION_WIDTH
+}
+
+func openRegionFile(path string) (*regionFile, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("os.OpenFile(): %w", err)
+	}
+
+	r := &regionFile{
+		file: file,
+		used: make([]bool, regionHeaderSectors),
+	}
+	for i := range r.used {
+		r.used[i] = true
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("file.Stat(): %w", err)
+	}
+
+	header := make([]byte, regionHeaderSectors*REGION_SECTOR_SIZE)
+	if info.Size() == 0 {
+		// new file, write an empty header
+		if _, err = file.WriteAt(header, 0); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("file.WriteAt(): %w", err)
+		}
+		return r, nil
+	}
+
+	// a shorter file was cut while it was written, its chunks can't be found
+	_, err = file.ReadAt(header, 0)
+	if errors.Is(err, io.EOF) {
+		file.Close()
+		return nil, fmt.Errorf("truncated region header in %s", path)
+	} else if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("file.ReadAt(): %w", err)
+	}
+
+	for i := range r.entries {
+		r.entries[i] = regionEntry{
+			sector: binary.BigEndian.Uint32(header[i*regionHeaderEntrySize:]),
+			length: binary.BigEndian.Uint32(header[i*regionHeaderEntrySize+4:]),
+		}
+		if r.entries[i].sector != 0 {
+			r.markSectors(r.entries[i], true)
+		}
+	}
+
+	return r, nil
+}
+
+func (r *regionFile) markSectors(e regionEntry, used bool) {
+	end := int(e.sector) + e.sectorCount()
+	for len(r.used) < end {
+		r.used = append(r.used, false)
+	}
+	for i := int(e.sector); i < end; i++ {
+		r.used[i] = used
+	}
+}
+
+// allocate returns the first run of n free sectors, growing the file if needed
+func (r *regionFile) allocate(n int) uint32 {
+	run := 0
+	for i, used := range r.used {
+		if used {
+			run = 0
+			continue
+		}
+		run++
+		if run == n {
+			return uint32(i - n + 1)
+		}
+	}
+	return uint32(len(r.used) - run)
+}
+
+// read returns the payload of the chunk at index i, or nil if it was never saved
+func (r *regionFile) read(i int) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := r.entries[i]
+	if e.sector == 0 {
+		return nil, nil
+	}
+
+	data := make([]byte, e.length)
+	if _, err := r.file.ReadAt(data, int64(e.sector)*REGION_SECTOR_SIZE); err != nil {
+		return nil, fmt.Errorf("file.ReadAt(): %w", err)
+	}
+	return data, nil
+}
+
+// write stores the payload of the chunk at index i, reusing its sectors when it still fits in them.
+// An empty payload removes the chunk from the region.
+func (r *regionFile) write(i int, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.entries[i]
+	e := regionEntry{old.sector, uint32(len(data))}
+	if len(data) == 0 {
+		if old.sector != 0 {
+			r.markSectors(old, false)
+		}
+		e = regionEntry{}
+	} else if old.sector == 0 || e.sectorCount() > old.sectorCount() {
+		if old.sector != 0 {
+			r.markSectors(old, false)
+		}
+		e.sector = r.allocate(e.sectorCount())
+	} else if e.sectorCount() < old.sectorCount() {
+		r.markSectors(old, false)
+	}
+	r.markSectors(e, true)
+
+	padded := make([]byte, e.sectorCount()*REGION_SECTOR_SIZE)
+	copy(padded, data)
+	if _, err := r.file.WriteAt(padded, int64(e.sector)*REGION_SECTOR_SIZE); err != nil {
+		return fmt.Errorf("file.WriteAt(): %w", err)
+	}
+
+	var header [regionHeaderEntrySize]byte
+	binary.BigEndian.PutUint32(header[:], e.sector)
+	binary.BigEndian.PutUint32(header[4:], e.length)
+	if _, err := r.file.WriteAt(header[:], int64(i*regionHeaderEntrySize)); err != nil {
+		return fmt.Errorf("file.WriteAt(): %w", err)
+	}
+	r.entries[i] = e
+
+	return nil
+}
+
+func (r *regionFile) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
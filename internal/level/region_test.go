@@ -0,0 +1,68 @@
+package level
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegionFileReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "r.0.0.mcr")
+	r, err := openRegionFile(path)
+	if err != nil {
+		t.Fatalf("openRegionFile(): %v", err)
+	}
+
+	// a payload over a sector long, one that is rewritten shorter and one that is removed
+	payloads := map[int][]byte{
+		3:   bytes.Repeat([]byte{7}, REGION_SECTOR_SIZE+10),
+		40:  []byte("short"),
+		900: []byte("removed"),
+	}
+	for i, data := range payloads {
+		if err := r.write(i, data); err != nil {
+			t.Fatalf("write(%d): %v", i, err)
+		}
+	}
+	if err := r.write(3, []byte("rewritten")); err != nil {
+		t.Fatalf("write(3): %v", err)
+	}
+	if err := r.write(900, nil); err != nil {
+		t.Fatalf("write(900): %v", err)
+	}
+	if err := r.close(); err != nil {
+		t.Fatalf("close(): %v", err)
+	}
+
+	r, err = openRegionFile(path)
+	if err != nil {
+		t.Fatalf("openRegionFile() of the saved file: %v", err)
+	}
+	defer r.close()
+	want := map[int][]byte{3: []byte("rewritten"), 40: []byte("short"), 900: nil, 0: nil}
+	for i, data := range want {
+		got, err := r.read(i)
+		if err != nil {
+			t.Fatalf("read(%d): %v", i, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("read(%d) = %q, want %q", i, got, data)
+		}
+	}
+}
+
+func TestRegionFileTruncatedHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "r.0.0.mcr")
+	if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+		t.Fatalf("os.WriteFile(): %v", err)
+	}
+
+	if r, err := openRegionFile(path); err == nil {
+		r.close()
+		t.Fatal("openRegionFile() of a truncated file succeeded, its chunks would be lost")
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 100 {
+		t.Errorf("the truncated file was changed")
+	}
+}
@@ -0,0 +1,171 @@
+package level
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+// SectionVisibility tells which sides of a section see each other through the blocks that aren't opaque, bit a*6+b for the sides a and b
+type SectionVisibility uint64
+
+const allVisible SectionVisibility = 1<<36 - 1
+
+func (v SectionVisibility) Connected(a, b int) bool {
+	return v&(1<<(a*6+b)) != 0
+}
+
+// connect connects every pair of the sides set in sides
+func (v *SectionVisibility) connect(sides uint8) {
+	for a := range 6 {
+		for b := range 6 {
+			if sides&(1<<a) != 0 && sides&(1<<b) != 0 {
+				*v |= 1 << (a*6 + b)
+			}
+		}
+	}
+}
+
+// opaque returns true if b fills its whole block and can't be seen through
+func (b BlockId) opaque() bool {
+	if b == AIR || BLOCK_TYPES[b.Type()].isTransparent {
+		return false
+	}
+	for _, coverage := range b.model().coverage {
+		if coverage != fullCoverage {
+			return false
+		}
+	}
+	return true
+}
+
+// sectionSides returns the sides of its section the block at p in the section touches
+func sectionSides(p utils.IntVector3) uint8 {
+	var sides uint8
+	if p.Y == SECTION_HEIGHT-1 {
+		sides |= 1 << faceTop
+	}
+	if p.Y == 0 {
+		sides |= 1 << faceBottom
+	}
+	if p.X == 0 {
+		sides |= 1 << faceLeft
+	}
+	if p.X == CHUNK_WIDTH-1 {
+		sides |= 1 << faceRight
+	}
+	if p.Z == CHUNK_WIDTH-1 {
+		sides |= 1 << faceFront
+	}
+	if p.Z == 0 {
+		sides |= 1 << faceBack
+	}
+	return sides
+}
+
+// visibility flood fills the blocks of the section that aren't opaque, the sides touched by the same fill see each other
+func (s *blockSection) visibility() SectionVisibility {
+	opaque := make([]bool, len(s.palette))
+	for i, b := range s.palette {
+		opaque[i] = b.opaque()
+	}
+
+	var v SectionVisibility
+	var visited [sectionVolume]bool
+	stack := make([]utils.IntVector3, 0, sectionVolume)
+	fill := func(start utils.IntVector3) uint8 {
+		var sides uint8
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			sides |= sectionSides(p)
+
+			for _, normal := range faceNormals {
+				n := p.Add(normal)
+				if n.X < 0 || n.X >= CHUNK_WIDTH || n.Y < 0 || n.Y >= SECTION_HEIGHT || n.Z < 0 || n.Z >= CHUNK_WIDTH {
+					continue
+				}
+				if i := sectionIndex(n.X, n.Y, n.Z); !visited[i] && !opaque[s.index(i)] {
+					visited[i] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+		return sides
+	}
+
+	for x := range CHUNK_WIDTH {
+		for y := range SECTION_HEIGHT {
+			for z := range CHUNK_WIDTH {
+				if i := sectionIndex(x, y, z); !visited[i] && !opaque[s.index(i)] {
+					visited[i] = true
+					v.connect(fill(utils.IntVector3{X: x, Y: y, Z: z}))
+				}
+			}
+		}
+	}
+	return v
+}
+
+/*
+VisibleSections returns the sections that can be seen from camera, as the sets of sections of every chunk.
+It flood fills from the section of camera, only leaving a section through a side its visibility connects to the one it was entered from,
+and never going back towards the camera, so sections seen through a chain of sides the fill can't take are left out.
+visibility returns the visibility of a section of a chunk, ok is false if it isn't loaded, the fill doesn't enter the sections inView rejects.
+ok is false if camera isn't in a loaded section, nothing can be culled then.
+*/
+func VisibleSections(
+	camera mgl32.Vec3,
+	visibility func(chunk utils.IntVector2, section int) (v SectionVisibility, ok bool),
+	inView func(chunk utils.IntVector2, section int) bool,
+) (visible map[utils.IntVector2]SectionSet, ok bool) {
+	type step struct {
+		chunk      utils.IntVector2
+		section    int
+		visibility SectionVisibility
+		from       int   // side the section was entered through, faceNone for the one of the camera
+		directions uint8 // sides the fill went out of on its way there
+	}
+
+	y := int(math.Floor(float64(camera.Y())))
+	if y < MIN_Y || y >= MAX_Y {
+		return nil, false
+	}
+	start := step{chunk: LevelToChunkCoords(camera), from: faceNone}
+	start.section, _ = sectionOf(y)
+	start.visibility, ok = visibility(start.chunk, start.section)
+	if !ok {
+		return nil, false
+	}
+
+	visible = map[utils.IntVector2]SectionSet{start.chunk: 1 << start.section}
+	queue := []step{start}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		for side, normal := range faceNormals {
+			if s.directions&(1<<opposite(side)) != 0 || s.from != faceNone && !s.visibility.Connected(s.from, side) {
+				continue
+			}
+
+			next := step{
+				chunk:      s.chunk.Add(utils.IntVector2{X: normal.X, Y: normal.Z}),
+				section:    s.section + normal.Y,
+				from:       opposite(side),
+				directions: s.directions | 1<<side,
+			}
+			if next.section < 0 || next.section >= SECTION_COUNT || visible[next.chunk].Has(next.section) {
+				continue
+			}
+			if next.visibility, ok = visibility(next.chunk, next.section); !ok || !inView(next.chunk, next.section) {
+				continue
+			}
+			visible[next.chunk] |= 1 << next.section
+			queue = append(queue, next)
+		}
+	}
+	return visible, true
+}
@@ -0,0 +1,73 @@
+package level
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+func TestVisibleSectionsSkipsBuriedSections(t *testing.T) {
+	l, m := newTestLevel(1)
+	var ground chunkBlocks
+	for x := range CHUNK_WIDTH {
+		for y := MIN_Y; y < 64; y++ {
+			for z := range CHUNK_WIDTH {
+				ground[x][y-MIN_Y][z] = STONE
+			}
+		}
+	}
+	var chunks []*Chunk
+	for x := -1; x <= 1; x++ {
+		for z := -1; z <= 1; z++ {
+			c := addTestChunk(l, m, utils.IntVector2{X: x, Y: z})
+			blocks := ground
+			c.setBlocks(c.getCoordinates(), STATUS_FULL, &blocks, false)
+			chunks = append(chunks, c)
+		}
+	}
+	remesh := func() {
+		for _, c := range chunks {
+			c.generateMesh(l)
+		}
+	}
+
+	visibility := func(coordinates utils.IntVector2, section int) (SectionVisibility, bool) {
+		// the slots of the level wrap around, only the chunks added above are loaded
+		c := l.getChunk(coordinates)
+		if c == nil || c.getCoordinates() != coordinates {
+			return 0, false
+		}
+		return c.SectionMesh(section).Visibility, true
+	}
+	everywhere := func(utils.IntVector2, int) bool { return true }
+	origin := utils.IntVector2{}
+	check := func(seen, hidden []int) {
+		t.Helper()
+		visible, ok := VisibleSections(mgl32.Vec3{7.5, 70.5, 7.5}, visibility, everywhere)
+		if !ok {
+			t.Fatal("the camera isn't in a loaded section")
+		}
+		for _, i := range seen {
+			if !visible[origin].Has(i) {
+				t.Errorf("section %d can't be seen, the visible sections are %b", i, visible[origin])
+			}
+		}
+		for _, i := range hidden {
+			if visible[origin].Has(i) {
+				t.Errorf("section %d can be seen, the visible sections are %b", i, visible[origin])
+			}
+		}
+	}
+
+	// the top of the ground can be seen, not what is under it
+	remesh()
+	check([]int{8, 7}, []int{6})
+
+	// a shaft lets the camera see the sections it goes through
+	for y := range 64 {
+		chunks[4].setBlock(utils.IntVector3{X: 7, Y: y, Z: 7}, AIR)
+	}
+	remesh()
+	check([]int{6, 5, 4, 3}, []int{2})
+}
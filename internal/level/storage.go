@@ -0,0 +1,289 @@
+package level
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/vparent05/minecraft_go/internal/utils"
+)
+
+/*
+A world directory is laid out as:
+
+	<world>/
+		level.json          world information, see WorldInfo
+		region/
+			r.<x>.<z>.mcr   region files of the chunks, see region.go
+		pending/
+			r.<x>.<z>.mcr   region files of the features waiting for their chunk to be generated, see pendingWrites.go
+*/
+
+const (
+	chunksDirectory  = "region"
+	pendingDirectory = "pending"
+)
+
+const chunkFormatVersion = 4
+
+const (
+	compressionNone = iota
+	compressionZlib
+)
+
+// WorldInfo holds the world wide settings chosen when the world was created
+type WorldInfo struct {
+	Seed      int64  `json:"seed"`
+	Generator string `json:"generator"` // one of the GENERATOR_* constants, see NewGenerator
+	Preset    string `json:"preset,omitempty"`
+}
+
+// WorldStorage persists the chunks of a world directory, it is safe for concurrent use.
+// A nil *WorldStorage never finds any chunk and discards every save.
+type WorldStorage struct {
+	path    string
+	mu      sync.Mutex
+	regions map[regionKey]*regionFile
+}
+
+type regionKey struct {
+	directory   string
+	coordinates utils.IntVector2
+}
+
+// OpenWorldStorage opens the world directory at path, creating it if it doesn't exist
+func OpenWorldStorage(path string) (*WorldStorage, error) {
+	for _, directory := range []string{chunksDirectory, pendingDirectory} {
+		if err := os.MkdirAll(filepath.Join(path, directory), 0755); err != nil {
+			return nil, fmt.Errorf("os.MkdirAll(): %w", err)
+		}
+	}
+
+	return &WorldStorage{
+		path:    path,
+		regions: make(map[regionKey]*regionFile),
+	}, nil
+}
+
+// LoadInfo returns the information of the world, ok is false if it was never saved
+func (s *WorldStorage) LoadInfo() (info WorldInfo, ok bool, err error) {
+	data, err := os.ReadFile(filepath.Join(s.path, "level.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return info, false, nil
+	} else if err != nil {
+		return info, false, fmt.Errorf("os.ReadFile(): %w", err)
+	}
+
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return info, false, fmt.Errorf("json.Unmarshal(): %w", err)
+	}
+	return info, true, nil
+}
+
+func (s *WorldStorage) SaveInfo(info WorldInfo) error {
+	data, err := json.MarshalIndent(info, "", "\t")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent(): %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(s.path, "level.json"), data, 0644)
+	if err != nil {
+		return fmt.Errorf("os.WriteFile(): %w", err)
+	}
+	return nil
+}
+
+func (s *WorldStorage) region(directory string, coordinates utils.IntVector2) (*regionFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := regionKey{directory, coordinates}
+	if r, ok := s.regions[key]; ok {
+		return r, nil
+	}
+
+	r, err := openRegionFile(filepath.Join(s.path, directory, fmt.Sprintf("r.%d.%d.mcr", coordinates.X, coordinates.Y)))
+	if err != nil {
+		return nil, fmt.Errorf("openRegionFile(): %w", err)
+	}
+	s.regions[key] = r
+	return r, nil
+}
+
+// read returns the payload stored for the chunk in the region files of directory, nil if there is none
+func (s *WorldStorage) read(directory string, chunkCoordinates utils.IntVector2) ([]byte, error) {
+	regionCoordinates, i := regionCoords(chunkCoordinates)
+	r, err := s.region(directory, regionCoordinates)
+	if err != nil {
+		return nil, fmt.Errorf("region(): %w", err)
+	}
+
+	data, err := r.read(i)
+	if err != nil {
+		return nil, fmt.Errorf("read(): %w", err)
+	}
+	return data, nil
+}
+
+// write stores the payload of the chunk in the region files of directory, an empty payload removes it
+func (s *WorldStorage) write(directory string, chunkCoordinates utils.IntVector2, data []byte) error {
+	regionCoordinates, i := regionCoords(chunkCoordinates)
+	r, err := s.region(directory, regionCoordinates)
+	if err != nil {
+		return fmt.Errorf("region(): %w", err)
+	}
+
+	err = r.write(i, data)
+	if err != nil {
+		return fmt.Errorf("write(): %w", err)
+	}
+	return nil
+}
+
+// loadChunk returns the saved blocks of the chunk and the generation stage they reached, ok is false if the chunk was never saved
+func (s *WorldStorage) loadChunk(chunkCoordinates utils.IntVector2) (blocks chunkBlocks, status ChunkStatus, ok bool, err error) {
+	if s == nil {
+		return blocks, STATUS_EMPTY, false, nil
+	}
+
+	data, err := s.read(chunksDirectory, chunkCoordinates)
+	if err != nil {
+		return blocks, STATUS_EMPTY, false, fmt.Errorf("read(): %w", err)
+	}
+	if data == nil {
+		return blocks, STATUS_EMPTY, false, nil
+	}
+
+	status, err = decodeChunk(data, &blocks)
+	if err != nil {
+		return blocks, STATUS_EMPTY, false, fmt.Errorf("decodeChunk(): %w", err)
+	}
+	return blocks, status, true, nil
+}
+
+func (s *WorldStorage) saveChunk(chunkCoordinates utils.IntVector2, status ChunkStatus, blocks *chunkBlocks) error {
+	if s == nil {
+		return nil
+	}
+
+	data, err := encodeChunk(status, blocks)
+	if err != nil {
+		return fmt.Errorf("encodeChunk(): %w", err)
+	}
+
+	err = s.write(chunksDirectory, chunkCoordinates, data)
+	if err != nil {
+		return fmt.Errorf("write(): %w", err)
+	}
+	return nil
+}
+
+// Close closes every open region file
+func (s *WorldStorage) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for key, r := range s.regions {
+		if err := r.close(); err != nil {
+			errs = append(errs, fmt.Errorf("close(): %w", err))
+		}
+		delete(s.regions, key)
+	}
+	return errors.Join(errs...)
+}
+
+// encodeChunk encodes the blocks as: format version (1 byte) | status (1 byte) | compression (1 byte) | compressed data,
+// the data is a namePalette followed by the palette index (uint16) of every block in x, y, z order, from MIN_Y to MAX_Y
+func encodeChunk(status ChunkStatus, blocks *chunkBlocks) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(chunkFormatVersion)
+	buf.WriteByte(byte(status))
+	buf.WriteByte(compressionZlib)
+
+	palette := newNamePalette()
+	indices := make([]byte, 0, 2*CHUNK_WIDTH*CHUNK_HEIGHT*CHUNK_WIDTH)
+	for x := range blocks {
+		for y := range blocks[x] {
+			for z := range blocks[x][y] {
+				indices = binary.BigEndian.AppendUint16(indices, palette.index(blocks[x][y][z]))
+			}
+		}
+	}
+	var raw bytes.Buffer
+	palette.encode(&raw)
+	raw.Write(indices)
+
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(raw.Bytes()); err != nil {
+		return nil, fmt.Errorf("w.Write(): %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("w.Close(): %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decodeChunk(data []byte, blocks *chunkBlocks) (ChunkStatus, error) {
+	if len(data) < 3 {
+		return STATUS_EMPTY, errors.New("truncated chunk payload")
+	}
+	if data[0] != chunkFormatVersion {
+		return STATUS_EMPTY, fmt.Errorf("unsupported chunk format version %d", data[0])
+	}
+	if ChunkStatus(data[1]) > STATUS_FULL {
+		return STATUS_EMPTY, errors.New("invalid chunk status")
+	}
+	status := ChunkStatus(data[1])
+	data = data[2:]
+
+	var r io.Reader = bytes.NewReader(data[1:])
+	switch data[0] {
+	case compressionNone:
+	case compressionZlib:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return STATUS_EMPTY, fmt.Errorf("zlib.NewReader(): %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return STATUS_EMPTY, fmt.Errorf("unsupported chunk compression %d", data[0])
+	}
+
+	ids, err := decodeNamePalette(r)
+	if err != nil {
+		return STATUS_EMPTY, fmt.Errorf("decodeNamePalette(): %w", err)
+	}
+	raw := make([]byte, 2*CHUNK_WIDTH*CHUNK_HEIGHT*CHUNK_WIDTH)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return STATUS_EMPTY, fmt.Errorf("io.ReadFull(): %w", err)
+	}
+
+	i := 0
+	for x := range blocks {
+		for y := range CHUNK_HEIGHT {
+			for z := range CHUNK_WIDTH {
+				blocks[x][y][z], err = paletteBlock(ids, binary.BigEndian.Uint16(raw[i:]))
+				if err != nil {
+					return STATUS_EMPTY, fmt.Errorf("paletteBlock(): %w", err)
+				}
+				i += 2
+			}
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,29 @@
+package level
+
+import "testing"
+
+func TestEncodeChunkRoundTrip(t *testing.T) {
+	furnace, _ := blockByName("furnace[facing=east,lit=true]")
+	log, _ := blockByName("log[axis=x]")
+	var blocks chunkBlocks
+	blocks[0][0][0] = STONE
+	blocks[3][-MIN_Y][7] = furnace
+	blocks[CHUNK_WIDTH-1][CHUNK_HEIGHT-1][CHUNK_WIDTH-1] = log
+	blocks[5][100][5] = WATER
+
+	data, err := encodeChunk(STATUS_FEATURES, &blocks)
+	if err != nil {
+		t.Fatalf("encodeChunk(): %v", err)
+	}
+	var decoded chunkBlocks
+	status, err := decodeChunk(data, &decoded)
+	if err != nil {
+		t.Fatalf("decodeChunk(): %v", err)
+	}
+	if status != STATUS_FEATURES {
+		t.Errorf("decodeChunk() status = %d, want %d", status, STATUS_FEATURES)
+	}
+	if decoded != blocks {
+		t.Error("the decoded blocks differ from the encoded ones")
+	}
+}
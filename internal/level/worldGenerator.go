@@ -1,7 +1,7 @@
 package level
 
 import (
-	"math"
+	"fmt"
 	"sync"
 
 	"github.com/vparent05/minecraft_go/internal/utils"
@@ -10,25 +10,42 @@ import (
 
 const WORLD_GENERATOR_WORKER_COUNT = 2
 
+/*
+worldGenerator brings the chunks of the level through the generation stages.
+A chunk whose next stage needs neighbours that aren't far enough waits until one of them advances.
+*/
 type worldGenerator struct {
 	mu         sync.Mutex
 	wg         sync.WaitGroup
 	stop       chan struct{}
 	toGenerate chan *Chunk
+	level      *Level
+
+	waitingMu sync.Mutex
+	waiting   map[*Chunk]utils.IntVector2 // chunks waiting for their neighbours, with the coordinates they wait at
+	ready     []*Chunk                    // chunks whose neighbours advanced since they started waiting
+	newReady  chan struct{}
 }
 
-func newWorldGenerator() *worldGenerator {
+func newWorldGenerator(level *Level) *worldGenerator {
 	w := &worldGenerator{
 		mu:         sync.Mutex{},
 		wg:         sync.WaitGroup{},
 		stop:       make(chan struct{}),
 		toGenerate: make(chan *Chunk),
+		level:      level,
+		waiting:    make(map[*Chunk]utils.IntVector2),
+		newReady:   make(chan struct{}, 1),
 	}
 
 	return w
 }
 
 func (w *worldGenerator) enqueue(c *Chunk) {
+	w.waitingMu.Lock()
+	delete(w.waiting, c)
+	w.waitingMu.Unlock()
+
 	w.toGenerate <- c
 }
 
@@ -51,19 +68,23 @@ func (w *worldGenerator) newWorker() {
 		defer w.wg.Done()
 
 		for {
-			if _, ok := chanx.TryReceive(w.stop); ok {
-				return
-			}
-
 			w.mu.Lock()
 			toGenerate := w.toGenerate
 			w.mu.Unlock()
 
-			for chunk := range toGenerate {
-				generateChunk(chunk)
+			select {
+			case <-w.stop:
+				return
+			case chunk, ok := <-toGenerate:
+				if ok {
+					w.advance(chunk)
+				}
+				// channel closed -> queue reset -> loop and pick up new channel
+			case <-w.newReady:
+				if chunk, ok := w.popReady(); ok {
+					w.advance(chunk)
+				}
 			}
-
-			// channel closed -> queue reset -> loop and pick up new channel
 		}
 	}()
 }
@@ -73,42 +94,190 @@ func (m *worldGenerator) stopWorkers() {
 	m.wg.Wait()
 }
 
-func generateChunk(chunk *Chunk) {
-	const WATER_LEVEL = 60
-	var blocks [CHUNK_WIDTH][CHUNK_HEIGHT][CHUNK_WIDTH]BlockId
-
-	for i := range CHUNK_WIDTH {
-		for j := range CHUNK_WIDTH {
-			xBlock := i + chunk.coordinates.X*CHUNK_WIDTH
-			zBlock := j + chunk.coordinates.Y*CHUNK_WIDTH
-			topY := int(math.Floor(float64(utils.FractalNoise2(float32(xBlock), float32(zBlock), 6)+1)*30) + 35)
-
-			for k := range topY {
-				id := STONE // stone
-				if k == topY-1 {
-					if k <= WATER_LEVEL {
-						id = SAND // sand
-					} else {
-						id = GRASS // grass
-					}
-				} else if k > topY-5 {
-					if k <= WATER_LEVEL {
-						id = SAND // sand
-					} else {
-						id = DIRT // dirt
-					}
-				}
-				blocks[i][k][j] = id
-			}
+// advance runs the generation stages of the chunk until it is full or has to wait for its neighbours
+func (w *worldGenerator) advance(chunk *Chunk) {
+	coordinates := chunk.getCoordinates()
+	if chunk.statusAt(coordinates) == STATUS_EMPTY && w.loadChunk(chunk, coordinates) {
+		w.wakeNeighbours(coordinates)
+	}
 
-			for k := topY; k < WATER_LEVEL; k++ {
-				blocks[i][k][j] = WATER
-			}
-			if topY <= WATER_LEVEL {
-				blocks[i][WATER_LEVEL][j] = WATER
+	for {
+		status := chunk.statusAt(coordinates)
+		if status == STATUS_FULL {
+			return
+		}
+		stage := generationStages[status]
+
+		w.waitingMu.Lock()
+		if !w.neighboursReached(coordinates, stage.neighbours) {
+			w.waiting[chunk] = coordinates
+			w.waitingMu.Unlock()
+			return
+		}
+		w.waitingMu.Unlock()
+
+		if !stage.run(w, chunk, coordinates) {
+			return
+		}
+		w.wakeNeighbours(coordinates)
+	}
+}
+
+func (w *worldGenerator) neighboursReached(coordinates utils.IntVector2, status ChunkStatus) bool {
+	if status == STATUS_EMPTY {
+		return true
+	}
+	for dx := -1; dx <= 1; dx++ {
+		for dz := -1; dz <= 1; dz++ {
+			neighbour := utils.IntVector2{X: coordinates.X + dx, Y: coordinates.Y + dz}
+			if c := w.level.getChunk(neighbour); c == nil || c.statusAt(neighbour) < status {
+				return false
 			}
 		}
 	}
+	return true
+}
+
+// wakeNeighbours moves the chunks waiting around coordinates to the ready queue
+func (w *worldGenerator) wakeNeighbours(coordinates utils.IntVector2) {
+	w.waitingMu.Lock()
+	woken := false
+	for chunk, waitingAt := range w.waiting {
+		d := waitingAt.Sub(coordinates)
+		if max(d.X, -d.X, d.Y, -d.Y) <= 1 {
+			delete(w.waiting, chunk)
+			w.ready = append(w.ready, chunk)
+			woken = true
+		}
+	}
+	w.waitingMu.Unlock()
+
+	if woken {
+		chanx.TrySend(w.newReady, struct{}{})
+	}
+}
+
+func (w *worldGenerator) popReady() (*Chunk, bool) {
+	w.waitingMu.Lock()
+	defer w.waitingMu.Unlock()
+
+	if len(w.ready) == 0 {
+		return nil, false
+	}
+	chunk := w.ready[0]
+	w.ready = w.ready[1:]
+	if len(w.ready) > 0 {
+		// let another worker pick up the rest
+		chanx.TrySend(w.newReady, struct{}{})
+	}
+	return chunk, true
+}
+
+// loadChunk fills the chunk from storage, it returns false if the chunk has to be generated
+func (w *worldGenerator) loadChunk(chunk *Chunk, coordinates utils.IntVector2) bool {
+	blocks, status, ok, err := w.level.storage.loadChunk(coordinates)
+	if err != nil {
+		fmt.Println("Error loading chunk:", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	// the light isn't stored, loaded chunks are lit again
+	status = min(status, STATUS_FEATURES)
+
+	if status < STATUS_FEATURES {
+		// the pending writes are merged by the features stage
+		return chunk.setBlocks(coordinates, status, &blocks, false)
+	}
+
+	pending := w.level.pending
+	pending.mu.Lock()
+	defer pending.mu.Unlock()
+
+	writes, err := pending.take(coordinates)
+	if err != nil {
+		fmt.Println("Error loading pending writes:", err)
+	}
+	mergeWrites(&blocks, writes)
+
+	if !chunk.setBlocks(coordinates, status, &blocks, len(writes) > 0) {
+		// the chunk moved, its writes wait for the next time it is loaded
+		if err := pending.add(coordinates, writes); err != nil {
+			fmt.Println("Error saving pending writes:", err)
+		}
+		return false
+	}
+	return true
+}
+
+func (w *worldGenerator) generateTerrain(chunk *Chunk, coordinates utils.IntVector2) bool {
+	return chunk.setBlocks(coordinates, STATUS_TERRAIN, w.level.generator.GenerateTerrain(coordinates), false)
+}
+
+func (w *worldGenerator) carve(chunk *Chunk, coordinates utils.IntVector2) bool {
+	carver, ok := w.level.generator.(carver)
+	if !ok {
+		return chunk.setBlocks(coordinates, STATUS_CARVERS, nil, false)
+	}
+
+	blocks, ok := chunk.blocksAt(coordinates)
+	if !ok {
+		return false
+	}
+	carver.carve(coordinates, &blocks)
+	return chunk.setBlocks(coordinates, STATUS_CARVERS, &blocks, false)
+}
+
+/*
+placeFeatures places the features of the chunk and merges the blocks features of other chunks placed in it.
+Then it merges the blocks its features placed in other chunks into them, or keeps them pending if they didn't place theirs yet.
+The merges happen under the pending lock, so a chunk never misses writes made while it was placing its features.
+*/
+func (w *worldGenerator) placeFeatures(chunk *Chunk, coordinates utils.IntVector2) bool {
+	blocks, ok := chunk.blocksAt(coordinates)
+	if !ok {
+		return false
+	}
+
+	var outside map[utils.IntVector2][]blockWrite
+	if placer, ok := w.level.generator.(featurePlacer); ok {
+		outside = placer.placeFeatures(coordinates, &blocks)
+	}
+
+	pending := w.level.pending
+	pending.mu.Lock()
+	defer pending.mu.Unlock()
+
+	writes, err := pending.take(coordinates)
+	if err != nil {
+		fmt.Println("Error loading pending writes:", err)
+	}
+	mergeWrites(&blocks, writes)
+
+	if !chunk.setBlocks(coordinates, STATUS_FEATURES, &blocks, true) {
+		// the chunk moved, its writes wait for the next time it is loaded and its features are placed again when it is generated
+		if err := pending.add(coordinates, writes); err != nil {
+			fmt.Println("Error saving pending writes:", err)
+		}
+		return false
+	}
+
+	for target, writes := range outside {
+		if c := w.level.getChunk(target); c != nil && c.applyWrites(target, writes) {
+			continue
+		}
+		if err := pending.add(target, writes); err != nil {
+			fmt.Println("Error saving pending writes:", err)
+		}
+	}
+	return true
+}
+
+func (w *worldGenerator) light(chunk *Chunk, coordinates utils.IntVector2) bool {
+	return w.level.lightChunk(chunk, coordinates)
+}
 
-	chunk.setBlocks(blocks)
+func (w *worldGenerator) finish(chunk *Chunk, coordinates utils.IntVector2) bool {
+	return chunk.setBlocks(coordinates, STATUS_FULL, nil, false)
 }
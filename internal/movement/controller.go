@@ -6,7 +6,11 @@ import (
 	"github.com/go-gl/mathgl/mgl32"
 )
 
+// Collider returns how much of displacement can be done from position without going through anything
+type Collider func(position, displacement mgl32.Vec3) mgl32.Vec3
+
 type Controller struct {
+	collider     Collider
 	position     mgl32.Vec3
 	direction    mgl32.Vec3
 	velocity     float32
@@ -17,6 +21,7 @@ type Controller struct {
 
 func NewController(position mgl32.Vec3, acceleration, drag, maxVelocity float32) *Controller {
 	return &Controller{
+		nil,
 		position,
 		mgl32.Vec3{0, 0, 0},
 		0,
@@ -30,6 +35,11 @@ func (c *Controller) Position() mgl32.Vec3 {
 	return c.position
 }
 
+// SetCollider makes the controller stop where collider says, it goes through everything if collider is nil
+func (c *Controller) SetCollider(collider Collider) {
+	c.collider = collider
+}
+
 func (c *Controller) Move(deltaTime float32, accelerationDirection mgl32.Vec3) bool {
 	dragVec := c.direction.Mul(c.drag)
 	dragVec = dragVec.Sub(accelerationDirection.Mul(dragVec.Dot(accelerationDirection)))
@@ -40,6 +50,9 @@ func (c *Controller) Move(deltaTime float32, accelerationDirection mgl32.Vec3) b
 	if maxDisplacement := c.direction.Mul(c.maxVelocity * deltaTime); displacement.Len() > maxDisplacement.Len() {
 		displacement = maxDisplacement
 	}
+	if c.collider != nil {
+		displacement = c.collider(c.position, displacement)
+	}
 	c.position = c.position.Add(displacement)
 
 	newVelocityVec := accelerationVec.Mul(deltaTime).Add(velocityVec)
@@ -1,9 +1,6 @@
 package utils
 
-import (
-	"iter"
-	"unsafe"
-)
+import "iter"
 
 func FromOriginIterator1[T any](values []T, origin int) iter.Seq2[int, T] {
 	return func(yield func(int, T) bool) {
@@ -39,34 +36,36 @@ func FromOriginIterator2[T any](values [][]T, origin IntVector2) iter.Seq2[IntVe
 	}
 }
 
-func UnsafeFromOriginIterator1[T any](values *T, elementSize uintptr, start int, end int, size int, origin int) iter.Seq2[int, *T] {
-	return func(yield func(int, *T) bool) {
-		base := uintptr(unsafe.Pointer(values))
+// FromOriginRange1 returns the indices from start to end, wrapped in [0, size[, ending with origin, or the closest index to it in the range
+func FromOriginRange1(start, end, size, origin int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		if start >= end {
+			return
+		}
+		origin = min(max(origin, start), end-1)
 
 		// left [ start, origin [
 		for virtualI := start; virtualI < origin; virtualI++ {
-			i := Mod(virtualI, size)
-			if !yield(i, (*T)(unsafe.Pointer(base+uintptr(i)*elementSize))) {
+			if !yield(Mod(virtualI, size)) {
 				return
 			}
 		}
 
 		// right [ end, origin ]
 		for virtualI := end - 1; virtualI >= origin; virtualI-- {
-			i := Mod(virtualI, size)
-			if !yield(i, (*T)(unsafe.Pointer(base+uintptr(i)*elementSize))) {
+			if !yield(Mod(virtualI, size)) {
 				return
 			}
 		}
 	}
 }
 
-func UnsafeFromOriginIterator3[T any](values *T, start, end, size, origin IntVector3) iter.Seq2[IntVector3, T] {
-	return func(yield func(IntVector3, T) bool) {
-		for i, slice := range UnsafeFromOriginIterator1(values, uintptr(size.Y)*uintptr(size.Z)*unsafe.Sizeof(*values), start.X, end.X, size.X, origin.X) {
-			for j, row := range UnsafeFromOriginIterator1(slice, uintptr(size.Z)*unsafe.Sizeof(*values), start.Y, end.Y, size.Y, origin.Y) {
-				for k, t := range UnsafeFromOriginIterator1(row, unsafe.Sizeof(*values), start.Z, end.Z, size.Z, origin.Z) {
-					if !yield(IntVector3{i, j, k}, *t) {
+func FromOriginRange3(start, end, size, origin IntVector3) iter.Seq[IntVector3] {
+	return func(yield func(IntVector3) bool) {
+		for i := range FromOriginRange1(start.X, end.X, size.X, origin.X) {
+			for j := range FromOriginRange1(start.Y, end.Y, size.Y, origin.Y) {
+				for k := range FromOriginRange1(start.Z, end.Z, size.Z, origin.Z) {
+					if !yield(IntVector3{i, j, k}) {
 						return
 					}
 				}
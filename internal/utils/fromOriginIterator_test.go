@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFromOriginRange1(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin int
+		want   []int
+	}{
+		{"inside", 12, []int{0, 1, 4, 3, 2}},
+		{"before", 3, []int{4, 3, 2, 1, 0}},
+		{"after", 40, []int{0, 1, 2, 3, 4}},
+	}
+	for _, test := range tests {
+		// the range 10 to 15 wraps to the indices 0 to 4
+		if got := slices.Collect(FromOriginRange1(10, 15, 5, test.origin)); !slices.Equal(got, test.want) {
+			t.Errorf("%s: FromOriginRange1(10, 15, 5, %d) = %v, want %v", test.name, test.origin, got, test.want)
+		}
+	}
+}
+
+func TestFromOriginRange3OutsideOrigin(t *testing.T) {
+	start, end := IntVector3{X: 30, Y: 0, Z: -45}, IntVector3{X: 45, Y: 16, Z: -30}
+	size := IntVector3{X: 15, Y: 16, Z: 15}
+
+	// an origin outside the range still yields every index once
+	seen := make(map[IntVector3]bool)
+	for p := range FromOriginRange3(start, end, size, IntVector3{}) {
+		if seen[p] {
+			t.Fatalf("%v is yielded twice", p)
+		}
+		seen[p] = true
+	}
+	if len(seen) != size.X*size.Y*size.Z {
+		t.Errorf("%d indices are yielded, want %d", len(seen), size.X*size.Y*size.Z)
+	}
+}
@@ -1,21 +1,53 @@
 package utils
 
 import (
+	"encoding/binary"
+	"hash/fnv"
 	"math"
 	"math/rand"
 
 	"github.com/go-gl/mathgl/mgl32"
 )
 
-var PERMUTATION_ARRAY = generatePermutationArray(512)
+// Noise is a seeded gradient noise source, two Noise created from the same seed return the same values
+type Noise struct {
+	permutation []int
+}
+
+func NewNoise(seed int64) *Noise {
+	return &Noise{generatePermutationArray(512, rand.New(rand.NewSource(seed)))}
+}
+
+// DeriveSeed returns the seed of the stage called name of a world generated from seed,
+// so that every stage gets independent but reproducible values
+func DeriveSeed(seed int64, name string) int64 {
+	h := fnv.New64a()
+	binary.Write(h, binary.BigEndian, seed)
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
 
-func FractalNoise2(x float32, y float32, numberOfOctaves int) float32 {
+// DerivePositionSeed returns the seed of the stage called name at x, z of a world generated from seed
+func DerivePositionSeed(seed int64, name string, x, z int) int64 {
+	h := fnv.New64a()
+	binary.Write(h, binary.BigEndian, seed)
+	binary.Write(h, binary.BigEndian, int64(x))
+	binary.Write(h, binary.BigEndian, int64(z))
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+func (n *Noise) FractalNoise2(x float32, y float32, numberOfOctaves int) float32 {
+	return n.ScaledFractalNoise2(x, y, 0.005, numberOfOctaves)
+}
+
+// ScaledFractalNoise2 sums numberOfOctaves octaves of noise, starting at the given frequency
+func (n *Noise) ScaledFractalNoise2(x float32, y float32, frequency float32, numberOfOctaves int) float32 {
 	var result float32 = 0
 	var amplitude float32 = 1
-	var frequency float32 = 0.005
 
 	for i := 0; i < numberOfOctaves; i++ {
-		result += perlinNoise2(x*frequency, y*frequency) * amplitude
+		result += n.perlinNoise2(x*frequency, y*frequency) * amplitude
 		amplitude /= 2
 		frequency *= 2
 	}
@@ -23,7 +55,7 @@ func FractalNoise2(x float32, y float32, numberOfOctaves int) float32 {
 	return result
 }
 
-func perlinNoise2(x float32, y float32) float32 {
+func (n *Noise) perlinNoise2(x float32, y float32) float32 {
 	X := int(math.Floor(float64(x))) & 255
 	Y := int(math.Floor(float64(y))) & 255
 
@@ -35,10 +67,10 @@ func perlinNoise2(x float32, y float32) float32 {
 	bottomRight := mgl32.Vec2{xf - 1, yf}
 	bottomLeft := mgl32.Vec2{xf, yf}
 
-	valueTopRight := PERMUTATION_ARRAY[PERMUTATION_ARRAY[X+1]+Y+1]
-	valueTopLeft := PERMUTATION_ARRAY[PERMUTATION_ARRAY[X]+Y+1]
-	valueBottomRight := PERMUTATION_ARRAY[PERMUTATION_ARRAY[X+1]+Y]
-	valueBottomLeft := PERMUTATION_ARRAY[PERMUTATION_ARRAY[X]+Y]
+	valueTopRight := n.permutation[n.permutation[X+1]+Y+1]
+	valueTopLeft := n.permutation[n.permutation[X]+Y+1]
+	valueBottomRight := n.permutation[n.permutation[X+1]+Y]
+	valueBottomLeft := n.permutation[n.permutation[X]+Y]
 
 	dotTopRight := getConstantVector(valueTopRight).Dot(topRight)
 	dotTopLeft := getConstantVector(valueTopLeft).Dot(topLeft)
@@ -51,9 +83,59 @@ func perlinNoise2(x float32, y float32) float32 {
 	return lerp(u, lerp(v, dotBottomLeft, dotTopLeft), lerp(v, dotBottomRight, dotTopRight))
 }
 
-func shuffleArray(array []int) {
+func (n *Noise) FractalNoise3(x float32, y float32, z float32, numberOfOctaves int) float32 {
+	return n.ScaledFractalNoise3(x, y, z, 0.005, numberOfOctaves)
+}
+
+// ScaledFractalNoise3 sums numberOfOctaves octaves of 3D noise, starting at the given frequency
+func (n *Noise) ScaledFractalNoise3(x float32, y float32, z float32, frequency float32, numberOfOctaves int) float32 {
+	var result float32 = 0
+	var amplitude float32 = 1
+
+	for i := 0; i < numberOfOctaves; i++ {
+		result += n.perlinNoise3(x*frequency, y*frequency, z*frequency) * amplitude
+		amplitude /= 2
+		frequency *= 2
+	}
+
+	return result
+}
+
+func (n *Noise) perlinNoise3(x float32, y float32, z float32) float32 {
+	X := int(math.Floor(float64(x))) & 255
+	Y := int(math.Floor(float64(y))) & 255
+	Z := int(math.Floor(float64(z))) & 255
+
+	xf := x - float32(math.Floor(float64(x)))
+	yf := y - float32(math.Floor(float64(y)))
+	zf := z - float32(math.Floor(float64(z)))
+
+	p := n.permutation
+	A := p[X] + Y
+	B := p[X+1] + Y
+
+	dot000 := getConstantVector3(p[p[A]+Z]).Dot(mgl32.Vec3{xf, yf, zf})
+	dot100 := getConstantVector3(p[p[B]+Z]).Dot(mgl32.Vec3{xf - 1, yf, zf})
+	dot010 := getConstantVector3(p[p[A+1]+Z]).Dot(mgl32.Vec3{xf, yf - 1, zf})
+	dot110 := getConstantVector3(p[p[B+1]+Z]).Dot(mgl32.Vec3{xf - 1, yf - 1, zf})
+	dot001 := getConstantVector3(p[p[A]+Z+1]).Dot(mgl32.Vec3{xf, yf, zf - 1})
+	dot101 := getConstantVector3(p[p[B]+Z+1]).Dot(mgl32.Vec3{xf - 1, yf, zf - 1})
+	dot011 := getConstantVector3(p[p[A+1]+Z+1]).Dot(mgl32.Vec3{xf, yf - 1, zf - 1})
+	dot111 := getConstantVector3(p[p[B+1]+Z+1]).Dot(mgl32.Vec3{xf - 1, yf - 1, zf - 1})
+
+	u := fade(xf)
+	v := fade(yf)
+	w := fade(zf)
+
+	return lerp(w,
+		lerp(v, lerp(u, dot000, dot100), lerp(u, dot010, dot110)),
+		lerp(v, lerp(u, dot001, dot101), lerp(u, dot011, dot111)),
+	)
+}
+
+func shuffleArray(array []int, r *rand.Rand) {
 	for i := len(array) - 1; i > 0; i-- {
-		index := rand.Int() % i
+		index := r.Intn(i + 1)
 
 		temp := array[i]
 		array[i] = array[index]
@@ -61,12 +143,12 @@ func shuffleArray(array []int) {
 	}
 }
 
-func generatePermutationArray(size int) []int {
+func generatePermutationArray(size int, r *rand.Rand) []int {
 	array := make([]int, size/2)
 	for i := 0; i < size/2; i++ {
 		array[i] = i
 	}
-	shuffleArray(array)
+	shuffleArray(array, r)
 	finalArray := make([]int, size)
 	for i := 0; i < size/2; i++ {
 		finalArray[i] = array[i]
@@ -95,3 +177,15 @@ func getConstantVector(v int) mgl32.Vec2 {
 		return mgl32.Vec2{1, -1}
 	}
 }
+
+// gradients of 3D noise, the 12 edges of a cube (the first 4 are repeated to use 16 values)
+var constantVectors3 = [16]mgl32.Vec3{
+	{1, 1, 0}, {-1, 1, 0}, {1, -1, 0}, {-1, -1, 0},
+	{1, 0, 1}, {-1, 0, 1}, {1, 0, -1}, {-1, 0, -1},
+	{0, 1, 1}, {0, -1, 1}, {0, 1, -1}, {0, -1, -1},
+	{1, 1, 0}, {-1, 1, 0}, {0, -1, 1}, {0, -1, -1},
+}
+
+func getConstantVector3(v int) mgl32.Vec3 {
+	return constantVectors3[v&15]
+}
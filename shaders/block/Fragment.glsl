@@ -1,13 +1,17 @@
 #version 460 core
 flat in int orientation;
-in vec2 uv;
+flat in int textureIndex;
+in vec2 tileUV;
+in vec3 lightColor;
 
-uniform sampler2D atlas;
+uniform sampler2DArray blockTextures;
 
 out vec4 FragColor;
 void main()
 {		
-	vec4 col = texture(atlas, uv);
+	// merged quads repeat the texture, it wraps around its layer
+	vec4 col = texture(blockTextures, vec3(tileUV, textureIndex));
+	col.rgb *= lightColor;
 	
 	switch (orientation) {
 	case 0:
@@ -1,28 +1,48 @@
 #version 460 core
-layout (location = 0) in int vertex;
+layout (location = 0) in ivec3 vertex;
 
-uniform vec2 chunkCoordinates;
+// chunk coordinates in xy and y of the bottom of the section in z, for every draw command
+layout (std430, binding = 0) readonly buffer Draws {
+	vec4 draws[];
+};
 uniform mat4 view;
 uniform mat4 projection;
 
-out vec2 uv;
+out vec2 tileUV;
+flat out int textureIndex;
 flat out int orientation;
+out vec3 lightColor;
+
+// lightBrightness returns how bright a light level from 0 to 15 makes a face, every level is 0.8 times as bright as the next one
+float lightBrightness(int level)
+{
+	return max(pow(0.8, float(15 - level)), 0.05);
+}
+
 void main()
 {	
-	float x = ((vertex>>28) & 0xF) + chunkCoordinates.x * 15;
-	float y = (vertex>>20) & 0xFF;
-	float z = ((vertex>>16) & 0xF) + chunkCoordinates.y * 15;
-	orientation = (vertex>>12) & 0xF;
-	int textIndex = (vertex>>4) & 0xFF;
-
-	// level is only used for vertices of height y+1 
-	int level = vertex & 0xF;
-	gl_Position = projection * view * vec4(x, y - (15 - level)/16.0, float(z), 1.0);
-
-	uv = vec2(textIndex % 16, textIndex / 16) / 16.0;
-
-	// adjust the side texture to match the level
-	if (orientation != 0) {
-		uv.y += float(15 - level)/256.0;
-	}
-}
+	vec4 draw = draws[gl_BaseInstance];
+
+	// positions are in sixteenths of block
+	float x = ((vertex.x>>24) & 0xFF) / 16.0 + draw.x * 15;
+	float y = ((vertex.x>>12) & 0xFFF) / 16.0 + draw.z;
+	float z = ((vertex.x>>4) & 0xFF) / 16.0 + draw.y * 15;
+	orientation = vertex.x & 0xF;
+	gl_Position = projection * view * vec4(x, y, z, 1.0);
+
+	// texture coordinates are in sixteenths of the texture of the block, past 16 it repeats
+	textureIndex = (vertex.y>>18) & 0x3FFF;
+	int u = (vertex.y>>9) & 0x1FF;
+	int v = vertex.y & 0x1FF;
+	tileUV = vec2(u, v) / 16.0;
+
+	// the sky light in the low 4 bits, the red, green and blue block light above it
+	int sky = vertex.z & 0xF;
+	ivec3 block = (ivec3(vertex.z) >> ivec3(4, 8, 12)) & 0xF;
+	vec3 blockColor = vec3(lightBrightness(block.r), lightBrightness(block.g), lightBrightness(block.b));
+	lightColor = max(vec3(lightBrightness(sky)), blockColor);
+
+	// the ambient occlusion above the light, every block around the vertex darkens it
+	int occlusion = (vertex.z>>16) & 0x3;
+	lightColor *= 1.0 - 0.2 * occlusion;
+}
\ No newline at end of file